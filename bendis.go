@@ -1,20 +1,27 @@
 package bendis
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"fmt"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/gomodule/redigo/redis"
 	"github.com/robfig/cron/v3"
 	"github.com/zgoerbe/bendis/cache"
+	"github.com/zgoerbe/bendis/filesystems"
+	"github.com/zgoerbe/bendis/filesystems/encryptedfilesystem"
 	"github.com/zgoerbe/bendis/filesystems/miniofilesystem"
 	"github.com/zgoerbe/bendis/filesystems/s3filesystem"
 	"github.com/zgoerbe/bendis/filesystems/sftpfilsystem"
 	"github.com/zgoerbe/bendis/filesystems/webdavfilesystem"
+	"github.com/zgoerbe/bendis/images"
 	"github.com/zgoerbe/bendis/mailer"
+	"github.com/zgoerbe/bendis/passwords"
+	"io"
 	"log"
 	"net"
-	"net/rpc"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -24,7 +31,9 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
 	"github.com/zgoerbe/bendis/render"
+	"github.com/zgoerbe/bendis/scanner"
 	"github.com/zgoerbe/bendis/session"
+	"github.com/zgoerbe/bendis/urlsigner"
 )
 
 const version = "0.1.0"
@@ -34,8 +43,6 @@ var myBadgerCache *cache.BadgerCache
 var redisPool *redis.Pool
 var badgerConn *badger.DB
 
-var maintenanceMode bool
-
 // Bendis is the overall type for the Bendis package. Members that are exported in this type
 // are available to any application that uses it.
 type Bendis struct {
@@ -61,6 +68,7 @@ type Bendis struct {
 	SFTP          sftpfilsystem.SFTP
 	WebDAV        webdavfilesystem.WebDAV
 	Minio         miniofilesystem.Minio
+	Passwords     *passwords.Hasher
 }
 
 type Server struct {
@@ -71,18 +79,33 @@ type Server struct {
 }
 
 type config struct {
-	port        string
-	renderer    string
-	cookie      cookieConfig
-	sessionType string
-	database    databaseConfig
-	redis       RedisConfig
-	uploads     uploadConfig
+	port         string
+	renderer     string
+	cookie       cookieConfig
+	sessionType  string
+	database     databaseConfig
+	redis        RedisConfig
+	uploads      uploadConfig
+	locale       string
+	previousKeys [][]byte
+	legacyKey    []byte
+	maintenance  maintenanceConfig
+	proxies      []*net.IPNet
+	rpc          rpcConfig
+	jobs         jobsConfig
+	templates    *templateCache
+	logFile      *logFile
+	cors         CORSOptions
+	security     SecurityHeadersOptions
+	csrf         csrfConfig
 }
 
 type uploadConfig struct {
 	allowedMimeTypes []string
 	maxUploadSize    int64
+	naming           NamingStrategy
+	reuseNames       bool
+	scanner          scanner.Scanner
 }
 
 // New reads the .env file, creates our application config, populates the Bendis type with settings
@@ -110,7 +133,10 @@ func (b *Bendis) New(rootPath string) error {
 	}
 
 	// create loggers
+	b.RootPath = rootPath
 	infoLog, errorLog := b.startLoggers()
+	b.InfoLog = infoLog
+	b.ErrorLog = errorLog
 
 	// connect to database
 	if os.Getenv("DATABASE_TYPE") != "" {
@@ -134,12 +160,14 @@ func (b *Bendis) New(rootPath string) error {
 	var scheduler = cron.New()
 	b.Scheduler = scheduler
 
-	if os.Getenv("CACHE") == "badger" {
+	if os.Getenv("CACHE") == "badger" || os.Getenv("SESSION_TYPE") == "badger" {
 		myBadgerCache = b.createClientBadgerCache()
-		b.Cache = myBadgerCache
+		if os.Getenv("CACHE") == "badger" {
+			b.Cache = myBadgerCache
+		}
 		badgerConn = myBadgerCache.Conn
 
-		_, err = b.Scheduler.AddFunc("@daily", func() {
+		err = b.ScheduleJob("badger:gc", "@daily", func() {
 			_ = myBadgerCache.Conn.RunValueLogGC(0.7)
 		})
 		if err != nil {
@@ -147,11 +175,8 @@ func (b *Bendis) New(rootPath string) error {
 		}
 	}
 
-	b.InfoLog = infoLog
-	b.ErrorLog = errorLog
 	b.Debug, _ = strconv.ParseBool(os.Getenv("DEBUG"))
 	b.Version = version
-	b.RootPath = rootPath
 	b.Mail = b.createMailer()
 	b.Routes = b.routes().(*chi.Mux)
 
@@ -169,31 +194,52 @@ func (b *Bendis) New(rootPath string) error {
 		maxUploadSize = int64(max)
 	}
 
-	b.config = config{
-		port:     os.Getenv("PORT"),
-		renderer: os.Getenv("RENDERER"),
-		cookie: cookieConfig{
-			name:     os.Getenv("COOKIE_NAME"),
-			lifetime: os.Getenv("COOKIE_LIFETIME"),
-			persist:  os.Getenv("COOKIE_PERSISTS"),
-			secure:   os.Getenv("COOKIE_SECURE"),
-			domain:   os.Getenv("COOKIE_DOMAIN"),
-		},
-		sessionType: os.Getenv("SESSION_TYPE"),
-		database: databaseConfig{
-			database: os.Getenv("DATABASE_TYPE"),
-			dsn:      b.BuildDSN(),
-		},
-		redis: RedisConfig{
-			host:     os.Getenv("REDIS_HOST"),
-			password: os.Getenv("REDIS_PASSWORD"),
-			prefix:   os.Getenv("REDIS_PREFIX"),
-		},
-		uploads: uploadConfig{
-			maxUploadSize:    maxUploadSize,
-			allowedMimeTypes: mimeTypes,
-		},
+	if pixels, err := strconv.Atoi(os.Getenv("IMAGE_MAX_PIXELS")); err == nil && pixels > 0 {
+		images.MaxPixels = pixels
+	}
+
+	// set the fields one by one, as the loggers and jobs above have put theirs into config already
+	b.config.port = os.Getenv("PORT")
+	b.config.renderer = os.Getenv("RENDERER")
+	b.config.cookie = cookieConfig{
+		name:     os.Getenv("COOKIE_NAME"),
+		lifetime: os.Getenv("COOKIE_LIFETIME"),
+		persist:  os.Getenv("COOKIE_PERSISTS"),
+		secure:   os.Getenv("COOKIE_SECURE"),
+		domain:   os.Getenv("COOKIE_DOMAIN"),
+	}
+	b.config.sessionType = os.Getenv("SESSION_TYPE")
+	b.config.database = databaseConfig{
+		database: os.Getenv("DATABASE_TYPE"),
+		dsn:      b.BuildDSN(),
 	}
+	b.config.redis = RedisConfig{
+		host:     os.Getenv("REDIS_HOST"),
+		password: os.Getenv("REDIS_PASSWORD"),
+		prefix:   os.Getenv("REDIS_PREFIX"),
+	}
+	b.config.uploads = uploadConfig{
+		maxUploadSize:    maxUploadSize,
+		allowedMimeTypes: mimeTypes,
+		naming:           namingStrategy(os.Getenv("UPLOAD_NAMING")),
+		reuseNames:       strings.EqualFold(os.Getenv("UPLOAD_NAMING"), "hash"),
+		scanner:          b.createScanner(),
+	}
+	b.config.locale = os.Getenv("LOCALE")
+
+	b.config.proxies = b.networks("TRUSTED_PROXIES")
+	b.config.maintenance = b.maintenanceSettings()
+	b.config.cors = b.corsSettings()
+	b.config.security = b.securityHeadersSettings()
+	b.config.csrf = b.csrfSettings()
+	b.registerRPCCommands()
+
+	for _, key := range strings.Split(os.Getenv("PREVIOUS_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			b.config.previousKeys = append(b.config.previousKeys, []byte(key))
+		}
+	}
+	b.config.legacyKey = []byte(os.Getenv("LEGACY_KEY"))
 
 	secure := true
 	if strings.ToLower(os.Getenv("SECURE")) == "false" {
@@ -214,6 +260,11 @@ func (b *Bendis) New(rootPath string) error {
 		CookieName:     b.config.cookie.name,
 		SessionType:    b.config.sessionType,
 		CookieDomain:   b.config.cookie.domain,
+		CookieSecure:   b.config.cookie.secure,
+		CookieSameSite: os.Getenv("COOKIE_SAMESITE"),
+		IdleTimeout:    os.Getenv("SESSION_IDLE_TIMEOUT"),
+		EncryptionKey:  []byte(os.Getenv("KEY")),
+		PreviousKeys:   b.config.previousKeys,
 	}
 
 	switch b.config.sessionType {
@@ -221,10 +272,25 @@ func (b *Bendis) New(rootPath string) error {
 		sess.RedisPool = myRedisCache.Conn
 	case "mysql", "postgres", "mariadb", "postgresql":
 		sess.DBPool = b.DB.Pool
+	case "badger":
+		sess.BadgerConn = badgerConn
+	case "sqlite", "sqlite3":
+		db, err := b.OpenDB("sqlite3", b.sessionDatabasePath())
+		if err != nil {
+			return err
+		}
+		sess.DBPool = db
 	}
 
 	b.Session = sess.InitSession()
 	b.EncryptionKey = os.Getenv("KEY")
+	err = SetFieldEncryption(b.Encrypter(), b.blindIndexKey())
+	if err != nil {
+		b.ErrorLog.Println(err)
+	}
+
+	b.Passwords = b.createPasswordHasher()
+	passwords.Default = b.Passwords
 
 	if b.Debug {
 		var views = jet.NewSet(
@@ -233,8 +299,11 @@ func (b *Bendis) New(rootPath string) error {
 		)
 		b.JetViews = views
 	} else {
+		// parsed templates are kept until bendis rpc templates:reload
+		b.config.templates = &templateCache{}
 		var views = jet.NewSet(
 			jet.NewOSFileSystemLoader(fmt.Sprintf("%s/views", rootPath)),
+			jet.WithCache(b.config.templates),
 		)
 		b.JetViews = views
 	}
@@ -273,8 +342,24 @@ func (b *Bendis) startLoggers() (*log.Logger, *log.Logger) {
 	var infoLog *log.Logger
 	var errorLog *log.Logger
 
-	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	// log to LOG_FILE, relative to the root path, if set, so that logs can be rotated
+	var out io.Writer = os.Stdout
+	if name := os.Getenv("LOG_FILE"); name != "" {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(b.RootPath, name)
+		}
+
+		f, err := openLogFile(name)
+		if err != nil {
+			log.Println("logging to stdout:", err)
+		} else {
+			b.config.logFile = f
+			out = f
+		}
+	}
+
+	infoLog = log.New(out, "INFO\t", log.Ldate|log.Ltime)
+	errorLog = log.New(out, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
 	return infoLog, errorLog
 }
@@ -313,6 +398,65 @@ func (b *Bendis) createMailer() mailer.Mail {
 	return m
 }
 
+// createScanner returns a ClamAV scanner if CLAMAV_ADDRESS is set, either as host:port or as
+// unix:/path/to/clamd.socket
+func (b *Bendis) createScanner() scanner.Scanner {
+	address := os.Getenv("CLAMAV_ADDRESS")
+	if address == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(address, "unix:") {
+		return &scanner.ClamAV{Network: "unix", Address: strings.TrimPrefix(address, "unix:")}
+	}
+	return &scanner.ClamAV{Network: "tcp", Address: address}
+}
+
+// createPasswordHasher configures password hashing from HASH_ALGORITHM, ARGON2_MEMORY (in KiB),
+// ARGON2_TIME, ARGON2_THREADS and BCRYPT_COST; anything not set keeps its default, and so does
+// an argon2 setting of zero, which argon2 cannot hash with
+func (b *Bendis) createPasswordHasher() *passwords.Hasher {
+	h := passwords.New()
+
+	if algorithm := strings.ToLower(os.Getenv("HASH_ALGORITHM")); algorithm == passwords.Bcrypt {
+		h.Algorithm = passwords.Bcrypt
+	}
+
+	if memory, ok := b.argon2Setting("ARGON2_MEMORY", 32); ok {
+		h.Memory = uint32(memory)
+	}
+
+	if iterations, ok := b.argon2Setting("ARGON2_TIME", 32); ok {
+		h.Time = uint32(iterations)
+	}
+
+	if threads, ok := b.argon2Setting("ARGON2_THREADS", 8); ok {
+		h.Threads = uint8(threads)
+	}
+
+	if cost, err := strconv.Atoi(os.Getenv("BCRYPT_COST")); err == nil {
+		h.BcryptCost = cost
+	}
+
+	return h
+}
+
+// argon2Setting reads the environment variable key as a positive number of at most bits bits. Other
+// values are logged, and not used.
+func (b *Bendis) argon2Setting(key string, bits int) (uint64, bool) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(value, 10, bits)
+	if err != nil || n == 0 {
+		b.ErrorLog.Printf("%s must be a positive number, got %q; using the default", key, value)
+		return 0, false
+	}
+	return n, true
+}
+
 func (b *Bendis) createClientRedisCache() *cache.RedisCache {
 	cacheClient := cache.RedisCache{
 		Conn:   b.createRedisPool(),
@@ -358,6 +502,14 @@ func (b *Bendis) createBadgerConn() *badger.DB {
 	return db
 }
 
+// sessionDatabasePath returns SESSION_SQLITE_PATH, by default tmp/sessions.db under the root path
+func (b *Bendis) sessionDatabasePath() string {
+	if path := os.Getenv("SESSION_SQLITE_PATH"); path != "" {
+		return path
+	}
+	return b.RootPath + "/tmp/sessions.db"
+}
+
 func (b *Bendis) BuildDSN() string {
 	var dsn string
 
@@ -438,39 +590,72 @@ func (b *Bendis) createFileSystems() map[string]interface{} {
 	return fileSystems
 }
 
-type RPCServer struct{}
+// FileSystem returns the configured file system with the given name (S3, MINIO, SFTP or WEBDAV)
+// as a filesystems.FS, ready to be passed to UploadFile or filesystems.Sync
+func (b *Bendis) FileSystem(name string) (filesystems.FS, error) {
+	if b.FileSystems == nil {
+		b.FileSystems = b.createFileSystems()
+	}
 
-func (r *RPCServer) MaintenanceMode(inMaintenanceMode bool, resp *string) error {
-	if inMaintenanceMode {
-		maintenanceMode = true
-		*resp = "Server in maintenance mode"
-	} else {
-		maintenanceMode = false
-		*resp = "Server live!"
+	switch fs := b.FileSystems[strings.ToUpper(name)].(type) {
+	case s3filesystem.S3:
+		return &fs, nil
+	case miniofilesystem.Minio:
+		return &fs, nil
+	case sftpfilsystem.SFTP:
+		return &fs, nil
+	case webdavfilesystem.WebDAV:
+		return &fs, nil
 	}
-	return nil
+
+	return nil, fmt.Errorf("file system %s is not configured", name)
 }
 
-func (b *Bendis) listenRPC() {
-	// if nothing specified for rpc port, don't start
-	if os.Getenv("RPC_PORT") != "" {
-		b.InfoLog.Println("Starting RPC server on port", os.Getenv("RPC_PORT"))
-		err := rpc.Register(new(RPCServer))
-		if err != nil {
-			b.ErrorLog.Println(err)
-			return
-		}
-		listen, err := net.Listen("tcp", "127.0.0.1:"+os.Getenv("RPC_PORT"))
-		if err != nil {
-			b.ErrorLog.Println(err)
-			return
-		}
-		for {
-			rpcConn, err := listen.Accept()
-			if err != nil {
-				continue
-			}
-			go rpc.ServeConn(rpcConn)
-		}
+// URLSigner returns a signer using the application KEY, accepting urls signed with PREVIOUS_KEYS,
+// and remembering used single use urls in the application cache
+func (b *Bendis) URLSigner() *urlsigner.Signer {
+	return &urlsigner.Signer{
+		Secret:          []byte(b.EncryptionKey),
+		PreviousSecrets: b.config.previousKeys,
+		Cache:           b.Cache,
+	}
+}
+
+// blindIndexKey returns BLIND_INDEX_KEY, or a key derived from KEY, or nil if neither is set. Set
+// BLIND_INDEX_KEY before rotating KEY, since a new key would change every blind index.
+func (b *Bendis) blindIndexKey() []byte {
+	if key := os.Getenv("BLIND_INDEX_KEY"); key != "" {
+		return []byte(key)
+	}
+
+	if b.EncryptionKey == "" {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, []byte(b.EncryptionKey))
+	mac.Write([]byte("blind-index"))
+	return mac.Sum(nil)
+}
+
+// EncryptedFileSystem returns the named file system wrapped so that files are encrypted with the
+// application KEY before they leave the process, and decrypted again when they are retrieved. Files
+// encrypted with one of PREVIOUS_KEYS can still be retrieved.
+func (b *Bendis) EncryptedFileSystem(name string) (filesystems.FS, error) {
+	fs, err := b.FileSystem(name)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string][]byte)
+	for _, key := range b.config.previousKeys {
+		keys[keyID(key)] = key
 	}
+	keys[keyID([]byte(b.EncryptionKey))] = []byte(b.EncryptionKey)
+
+	return &encryptedfilesystem.Encrypted{
+		FS:      fs,
+		Keys:    keys,
+		KeyID:   keyID([]byte(b.EncryptionKey)),
+		TempDir: b.RootPath + "/tmp",
+	}, nil
 }
@@ -0,0 +1,43 @@
+package bendis
+
+import (
+	"bytes"
+	"github.com/zgoerbe/bendis/passwords"
+	"log"
+	"testing"
+)
+
+func TestBendis_CreatePasswordHasher(t *testing.T) {
+	defaults := passwords.New()
+
+	var tests = []struct {
+		name    string
+		memory  string
+		time    string
+		threads string
+		logged  bool
+		want    passwords.Hasher
+	}{
+		{"unset", "", "", "", false, *defaults},
+		{"set", "1024", "1", "4", false, passwords.Hasher{Memory: 1024, Time: 1, Threads: 4}},
+		{"zero", "0", "0", "0", true, *defaults},
+		{"not a number", "lots", "-1", "256", true, *defaults},
+	}
+
+	for _, e := range tests {
+		t.Setenv("ARGON2_MEMORY", e.memory)
+		t.Setenv("ARGON2_TIME", e.time)
+		t.Setenv("ARGON2_THREADS", e.threads)
+
+		var logged bytes.Buffer
+		b := &Bendis{ErrorLog: log.New(&logged, "", 0)}
+		h := b.createPasswordHasher()
+
+		if h.Memory != e.want.Memory || h.Time != e.want.Time || h.Threads != e.want.Threads {
+			t.Errorf("%s: expected m=%d,t=%d,p=%d, got m=%d,t=%d,p=%d", e.name, e.want.Memory, e.want.Time, e.want.Threads, h.Memory, h.Time, h.Threads)
+		}
+		if (logged.Len() > 0) != e.logged {
+			t.Errorf("%s: expected logged to be %t, got %q", e.name, e.logged, logged.String())
+		}
+	}
+}
@@ -84,6 +84,21 @@ func TestBadgerCache_Empty(t *testing.T) {
 		t.Error("alpha found in cache but it shouldn't be there")
 	}
 
+	err = testBadgerCache.Set(InternalPrefix+"session:abc", "data")
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = testBadgerCache.Empty()
+	if err != nil {
+		t.Error(err)
+	}
+
+	inCache, _ = testBadgerCache.Has(InternalPrefix + "session:abc")
+	if !inCache {
+		t.Error("internal key was removed by emptying the cache")
+	}
+	_ = testBadgerCache.Forget(InternalPrefix + "session:abc")
 }
 
 func TestBadgerCache_EmptyByMatch(t *testing.T) {
@@ -1,10 +1,15 @@
 package cache
 
 import (
+	"bytes"
 	"github.com/dgraph-io/badger/v3"
 	"time"
 )
 
+// InternalPrefix is put in front of keys that are kept in the cache's badger database but are not
+// cache entries, such as badger sessions, so that emptying the cache does not remove them
+const InternalPrefix = "_bendis:"
+
 type BadgerCache struct {
 	Conn   *badger.DB
 	Prefix string
@@ -90,6 +95,7 @@ func (b *BadgerCache) EmptyByMatch(str string) error {
 	return b.emptyByMatch(str)
 }
 
+// Empty removes every entry from the cache, but leaves keys under InternalPrefix alone
 func (b *BadgerCache) Empty() error {
 	return b.emptyByMatch("")
 }
@@ -123,6 +129,9 @@ func (b *BadgerCache) emptyByMatch(str string) error {
 
 		for it.Seek([]byte(str)); it.ValidForPrefix([]byte(str)); it.Next() {
 			key := it.Item().KeyCopy(nil)
+			if str == "" && bytes.HasPrefix(key, []byte(InternalPrefix)) {
+				continue
+			}
 			keysForDelete = append(keysForDelete, key)
 			keyCollected++
 			if keyCollected == collectSize {
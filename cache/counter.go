@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gomodule/redigo/redis"
+	"strconv"
+	"time"
+)
+
+// Counter is implemented by caches that can count atomically, across every instance that shares
+// them, as rate limiting needs
+type Counter interface {
+	// Increment adds one to the counter key, and returns its new value. A counter that does not
+	// exist yet starts at zero, and expires after expires seconds.
+	Increment(key string, expires int) (int, error)
+	// Count returns the value of the counter key, or zero if it does not exist
+	Count(key string) (int, error)
+}
+
+func (c *RedisCache) Increment(str string, expires int) (int, error) {
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	// create the counter with its expiry first, so that it can never be left without one
+	_, err := conn.Do("SET", key, 0, "EX", expires, "NX")
+	if err != nil {
+		return 0, err
+	}
+
+	return redis.Int(conn.Do("INCR", key))
+}
+
+func (c *RedisCache) Count(str string) (int, error) {
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	count, err := redis.Int(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (b *BadgerCache) Increment(str string, expires int) (int, error) {
+	for {
+		var count int
+		err := b.Conn.Update(func(txn *badger.Txn) error {
+			count = 0
+			expiresAt := uint64(time.Now().Add(time.Duration(expires) * time.Second).Unix())
+
+			item, err := txn.Get([]byte(str))
+			if err == nil {
+				err = item.Value(func(val []byte) error {
+					count, err = strconv.Atoi(string(val))
+					return err
+				})
+				if err != nil {
+					return err
+				}
+				expiresAt = item.ExpiresAt()
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+
+			count++
+			e := badger.NewEntry([]byte(str), []byte(strconv.Itoa(count)))
+			e.ExpiresAt = expiresAt
+			return txn.SetEntry(e)
+		})
+
+		// another request changed the counter at the same time; count again
+		if errors.Is(err, badger.ErrConflict) {
+			continue
+		}
+		return count, err
+	}
+}
+
+func (b *BadgerCache) Count(str string) (int, error) {
+	var count int
+	err := b.Conn.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(str))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			count, err = strconv.Atoi(string(val))
+			return err
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	return count, err
+}
@@ -0,0 +1,45 @@
+package cache
+
+import "testing"
+
+func testCounter(t *testing.T, name string, c Counter) {
+	count, err := c.Count("counter")
+	if err != nil {
+		t.Error(name, err)
+	}
+	if count != 0 {
+		t.Errorf("%s: counter that does not exist is %d, expected 0", name, count)
+	}
+
+	for i := 1; i <= 3; i++ {
+		count, err = c.Increment("counter", 60)
+		if err != nil {
+			t.Error(name, err)
+		}
+		if count != i {
+			t.Errorf("%s: counter is %d after incrementing, expected %d", name, count, i)
+		}
+	}
+
+	count, err = c.Count("counter")
+	if err != nil {
+		t.Error(name, err)
+	}
+	if count != 3 {
+		t.Errorf("%s: counter is %d, expected 3", name, count)
+	}
+}
+
+func TestRedisCache_Increment(t *testing.T) {
+	_ = testRedisCache.Forget("counter")
+	testCounter(t, "redis", &testRedisCache)
+}
+
+func TestBadgerCache_Increment(t *testing.T) {
+	_ = testBadgerCache.Forget("counter")
+	testCounter(t, "badger", &testBadgerCache)
+}
+
+func TestMemoryCache_Increment(t *testing.T) {
+	testCounter(t, "memory", NewMemoryCache())
+}
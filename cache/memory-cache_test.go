@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	m := NewMemoryCache()
+
+	err := m.Set("foo", "bar")
+	if err != nil {
+		t.Error(err)
+	}
+
+	x, err := m.Get("foo")
+	if err != nil {
+		t.Error(err)
+	}
+	if x != "bar" {
+		t.Error("did not get correct value from cache")
+	}
+
+	_ = m.Set("alpha", 1)
+	_ = m.Set("alpha2", 2)
+	err = m.EmptyByMatch("alpha")
+	if err != nil {
+		t.Error(err)
+	}
+	if inCache, _ := m.Has("alpha2"); inCache {
+		t.Error("alpha2 found in cache after emptying keys matching alpha")
+	}
+	if inCache, _ := m.Has("foo"); !inCache {
+		t.Error("foo not found in cache, and it should be there")
+	}
+
+	_ = m.Forget("foo")
+	if _, err := m.Get("foo"); err == nil {
+		t.Error("got foo from cache after forgetting it")
+	}
+}
+
+func TestMemoryCache_Expires(t *testing.T) {
+	m := NewMemoryCache()
+	m.entries["foo"] = memoryEntry{value: "bar", expires: time.Now().Add(-time.Second)}
+
+	if inCache, _ := m.Has("foo"); inCache {
+		t.Error("expired entry found in cache")
+	}
+}
+
+func TestMemoryCache_Sweep(t *testing.T) {
+	m := NewMemoryCache()
+	m.entries["old"] = memoryEntry{value: 1, expires: time.Now().Add(-time.Second)}
+	m.entries["kept"] = memoryEntry{value: 1}
+
+	_, _ = m.Increment("new", 60)
+	if _, ok := m.entries["old"]; !ok {
+		t.Error("expired entry was swept before the interval passed")
+	}
+
+	m.swept = time.Now().Add(-2 * sweepInterval)
+	_, _ = m.Increment("new", 60)
+	if _, ok := m.entries["old"]; ok {
+		t.Error("expired entry was not swept")
+	}
+	if len(m.entries) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(m.entries))
+	}
+}
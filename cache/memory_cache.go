@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errNotFound = errors.New("not found in cache")
+
+// sweepInterval is how often expired entries that are never asked for again are removed
+const sweepInterval = time.Minute
+
+// MemoryCache keeps entries in the memory of this instance only. It is meant for tests, and for
+// single instance applications without redis or badger.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	swept   time.Time
+}
+
+type memoryEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryEntry), swept: time.Now()}
+}
+
+// sweep removes expired entries, at most once every sweepInterval, so that keys which are never
+// read again, such as the counters of rate limits, do not pile up. The lock must be held.
+func (m *MemoryCache) sweep() {
+	now := time.Now()
+	if now.Sub(m.swept) < sweepInterval {
+		return
+	}
+	m.swept = now
+
+	for key, entry := range m.entries {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+// get returns the entry for key, removing it if it has expired. The lock must be held.
+func (m *MemoryCache) get(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if ok && !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, ok
+}
+
+func (m *MemoryCache) Has(str string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.get(str)
+	return ok, nil
+}
+
+func (m *MemoryCache) Get(str string) (interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.get(str)
+	if !ok {
+		return nil, errNotFound
+	}
+	return entry.value, nil
+}
+
+func (m *MemoryCache) Set(str string, value interface{}, expires ...int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep()
+	entry := memoryEntry{value: value}
+	if len(expires) > 0 {
+		entry.expires = time.Now().Add(time.Duration(expires[0]) * time.Second)
+	}
+	m.entries[str] = entry
+	return nil
+}
+
+func (m *MemoryCache) Forget(str string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, str)
+	return nil
+}
+
+func (m *MemoryCache) EmptyByMatch(str string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key := range m.entries {
+		if strings.HasPrefix(key, str) {
+			delete(m.entries, key)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryCache) Empty() error {
+	return m.EmptyByMatch("")
+}
+
+func (m *MemoryCache) Increment(str string, expires int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep()
+	entry, ok := m.get(str)
+	if !ok {
+		entry = memoryEntry{value: 0, expires: time.Now().Add(time.Duration(expires) * time.Second)}
+	}
+
+	count, _ := entry.value.(int)
+	entry.value = count + 1
+	m.entries[str] = entry
+	return count + 1, nil
+}
+
+func (m *MemoryCache) Count(str string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, _ := m.get(str)
+	count, _ := entry.value.(int)
+	return count, nil
+}
@@ -73,8 +73,11 @@ func showHelp() {
 	color.Yellow(`Available commands:
 
     help                           - show the help commands
-    down                           - put the server out in maintenance mode
+    down                           - put the server out in maintenance mode; flags: --at=<time>, --until=<time>,
+                                     --for=<duration>, --secret=<bypass>, --retry-after=<seconds>, --message=<text>
     up                             - take the server out in maintenance mode
+    status                         - show whether each instance of the server is in maintenance mode
+    rpc <command> [args]           - run an admin command on the server; bendis rpc help lists them
     version                        - print application version
     migrate                        - runs all up migrations that have not been run previously
     migrate down                   - reverses the most recent migrations
@@ -85,6 +88,8 @@ func showHelp() {
     make model <name>              - creates a new model in the data directory
     make session                   - creates a table in the database as a session store
     make mail <name>               - creates two starter mail templates in the mail directory
+    storage sync <from> <to>       - copies new and changed files between file systems, e.g. WEBDAV:/uploads MINIO:uploads;
+                                     flags: --dry-run, --delete, --concurrency=<n>
 `)
 }
 
@@ -28,7 +28,22 @@ func main() {
 		rpcClient(false)
 
 	case "down":
-		rpcClient(true)
+		err = doDown()
+		if err != nil {
+			exitGracefully(err)
+		}
+
+	case "status":
+		err = doStatus()
+		if err != nil {
+			exitGracefully(err)
+		}
+
+	case "rpc":
+		err = doRPC()
+		if err != nil {
+			exitGracefully(err)
+		}
 
 	case "new":
 		if arg2 == "" {
@@ -55,6 +70,11 @@ func main() {
 		if err != nil {
 			exitGracefully(err)
 		}
+	case "storage":
+		err = doStorage(arg2, arg3, arg4)
+		if err != nil {
+			exitGracefully(err)
+		}
 	default:
 		showHelp()
 	}
@@ -61,6 +61,7 @@ func doNew(appName string) {
 	env := string(data)
 	env = strings.ReplaceAll(env, "${APP_NAME}", appName)
 	env = strings.ReplaceAll(env, "${KEY}", bend.RandomString(32))
+	env = strings.ReplaceAll(env, "${RPC_SECRET}", bend.RandomString(32))
 
 	err = copyDataToFile([]byte(env), fmt.Sprintf("./%s/.env", appName))
 	if err != nil {
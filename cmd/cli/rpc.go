@@ -1,24 +1,198 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fatih/color"
+	"github.com/zgoerbe/bendis"
 	"net/rpc"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func rpcClient(inMaintenanceMode bool) {
-	rpcPort := os.Getenv("RPC_PORT")
-	c, err := rpc.Dial("tcp", "127.0.0.1:"+rpcPort)
+	c := rpcConnect()
+
+	var result string
+	err := c.Call("RPCServer.MaintenanceMode", inMaintenanceMode, &result)
 	if err != nil {
 		exitGracefully(err)
 	}
+	color.Yellow(result)
+}
+
+// doDown puts the server in maintenance mode, now or in a window given by flags:
+// --at=<time>, --until=<time> or --for=<duration>, --secret=<bypass secret>,
+// --retry-after=<seconds> and --message=<text>
+func doDown() error {
+	var window bendis.MaintenanceWindow
+	var duration time.Duration
+
+	for _, flag := range os.Args[2:] {
+		name, value := flag, ""
+		if i := strings.Index(flag, "="); i >= 0 {
+			name, value = flag[:i], flag[i+1:]
+		}
+
+		var err error
+		switch name {
+		case "--at":
+			window.Start, err = parseMaintenanceTime(value)
+		case "--until":
+			window.End, err = parseMaintenanceTime(value)
+		case "--for":
+			duration, err = time.ParseDuration(value)
+		case "--secret":
+			window.Secret = value
+		case "--retry-after":
+			window.RetryAfter, err = strconv.Atoi(value)
+		case "--message":
+			window.Message = value
+		default:
+			return fmt.Errorf("unknown flag: %s", flag)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s: %s", name, value)
+		}
+	}
+
+	if duration > 0 {
+		if !window.End.IsZero() {
+			return errors.New("use either --until or --for, not both")
+		}
+		start := window.Start
+		if start.IsZero() {
+			start = time.Now()
+		}
+		window.End = start.Add(duration)
+	}
+
+	c := rpcConnect()
 
-	fmt.Println("Connected...")
 	var result string
-	err = c.Call("RPCServer.MaintenanceMode", inMaintenanceMode, &result)
+	err := c.Call("RPCServer.ScheduleMaintenance", window, &result)
 	if err != nil {
-		exitGracefully(err)
+		return err
 	}
 	color.Yellow(result)
+
+	if window.Secret != "" {
+		color.Yellow("Bypass maintenance by visiting /%s", window.Secret)
+	}
+	return nil
+}
+
+// doRPC runs a command registered with RegisterRPC on the server
+func doRPC() error {
+	if len(os.Args) < 3 {
+		return errors.New("rpc requires a command; try bendis rpc help")
+	}
+
+	c := rpcConnect()
+
+	var result string
+	err := c.Call("RPCServer.Run", bendis.RPCRequest{Command: os.Args[2], Args: os.Args[3:]}, &result)
+	if err != nil {
+		return err
+	}
+
+	if result != "" {
+		color.Yellow(strings.TrimRight(result, "\n"))
+	}
+	return nil
+}
+
+// doStatus shows the maintenance state every instance last reported
+func doStatus() error {
+	c := rpcConnect()
+
+	var instances []bendis.InstanceStatus
+	err := c.Call("RPCServer.Status", true, &instances)
+	if err != nil {
+		return err
+	}
+
+	if len(instances) == 0 {
+		color.Yellow("No instances have reported their state")
+		return nil
+	}
+
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+
+	for _, instance := range instances {
+		state := "live"
+		switch {
+		case instance.Maintenance:
+			state = "maintenance"
+		case instance.Window != nil && instance.Window.Start.After(time.Now()):
+			state = fmt.Sprintf("live, maintenance scheduled from %s", instance.Window.Start.Format(time.RFC1123))
+		}
+
+		if instance.Maintenance && !instance.Window.End.IsZero() {
+			state = fmt.Sprintf("%s until %s", state, instance.Window.End.Format(time.RFC1123))
+		}
+
+		line := fmt.Sprintf("%-30s pid %-7d %s (last seen %s ago)", instance.Host, instance.PID, state,
+			time.Since(instance.SeenAt).Round(time.Second))
+
+		switch {
+		case time.Since(instance.SeenAt) > time.Minute:
+			color.Red(line + ", not responding")
+		case instance.Maintenance:
+			color.Yellow(line)
+		default:
+			color.Green(line)
+		}
+	}
+
+	return nil
+}
+
+// parseMaintenanceTime accepts RFC 3339 times, dates with a time such as 2006-01-02 15:04, and
+// times of day such as 22:00, which mean the next time the clock shows them
+func parseMaintenanceTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	clock, err := time.ParseInLocation("15:04", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now()
+	t := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local)
+	if t.Before(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func rpcConnect() *rpc.Client {
+	network, address := bendis.RPCAddress()
+	if network == "" {
+		exitGracefully(errors.New("set RPC_PORT or RPC_SOCKET in .env to reach the server"))
+	}
+
+	secret := os.Getenv("RPC_SECRET")
+	if secret == "" {
+		exitGracefully(errors.New("set RPC_SECRET in .env to reach the server"))
+	}
+
+	c, err := bendis.DialRPC(network, address, []byte(secret))
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	fmt.Println("Connected...")
+	return c
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/zgoerbe/bendis/filesystems"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func doStorage(arg2, arg3, arg4 string) error {
+	switch arg2 {
+	case "sync":
+		if arg3 == "" || arg4 == "" {
+			return errors.New("storage sync requires a source and a target, e.g. storage sync WEBDAV:/uploads MINIO:uploads")
+		}
+		return doStorageSync(arg3, arg4)
+	default:
+		return errors.New("storage requires a subcommand: (sync)")
+	}
+}
+
+func doStorageSync(source, target string) error {
+	fromName, fromPrefix := splitStorageArg(source)
+	toName, toPrefix := splitStorageArg(target)
+
+	from, err := bend.FileSystem(fromName)
+	if err != nil {
+		return err
+	}
+
+	to, err := bend.FileSystem(toName)
+	if err != nil {
+		return err
+	}
+
+	opts := filesystems.SyncOptions{
+		FromPrefix: fromPrefix,
+		ToPrefix:   toPrefix,
+		TempDir:    bend.RootPath + "/tmp",
+	}
+
+	// optional flags follow the source and target
+	for _, flag := range os.Args[5:] {
+		switch {
+		case flag == "--dry-run":
+			opts.DryRun = true
+		case flag == "--delete":
+			opts.Delete = true
+		case strings.HasPrefix(flag, "--concurrency="):
+			n, err := strconv.Atoi(strings.TrimPrefix(flag, "--concurrency="))
+			if err != nil {
+				return fmt.Errorf("invalid concurrency: %s", flag)
+			}
+			opts.Concurrency = n
+		default:
+			return fmt.Errorf("unknown flag: %s", flag)
+		}
+	}
+
+	result, err := filesystems.Sync(from, to, opts)
+	if err != nil {
+		return err
+	}
+
+	verb := "copied"
+	if opts.DryRun {
+		verb = "would copy"
+	}
+	for _, key := range result.Copied {
+		color.Green("%s %s", verb, key)
+	}
+
+	verb = "deleted"
+	if opts.DryRun {
+		verb = "would delete"
+	}
+	for _, key := range result.Deleted {
+		color.Yellow("%s %s", verb, key)
+	}
+
+	for key, err := range result.Errors {
+		color.Red("failed %s: %v", key, err)
+	}
+
+	color.Blue("%d copied, %d deleted, %d unchanged, %d failed",
+		len(result.Copied), len(result.Deleted), len(result.Unchanged), len(result.Errors))
+
+	if len(result.Errors) > 0 {
+		return errors.New("storage sync finished with errors")
+	}
+
+	return nil
+}
+
+// splitStorageArg splits an argument like MINIO:uploads into the file system name and the prefix
+func splitStorageArg(arg string) (string, string) {
+	parts := strings.SplitN(arg, ":", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,224 @@
+package bendis
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions say which cross origin requests browsers may make
+type CORSOptions struct {
+	AllowedOrigins   []string // origins such as https://app.example.com; * and https://*.example.com match several
+	AllowedMethods   []string // defaults to GET, HEAD, POST, PUT, PATCH and DELETE
+	AllowedHeaders   []string // request headers scripts may send; * allows any
+	ExposedHeaders   []string // response headers scripts may read
+	AllowCredentials bool     // whether cookies and authorization headers are sent along; never with the origin *
+	MaxAge           int      // seconds browsers may cache the answer to a preflight request; zero leaves it to them
+	Paths            []string // path globs the options apply to, e.g. /api/*; empty applies them everywhere
+}
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	defaultCORSHeaders = []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Requested-With"}
+)
+
+// corsSettings reads CORS_ALLOWED_ORIGINS, CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS,
+// CORS_EXPOSED_HEADERS and CORS_PATHS, all comma separated, CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE
+func (b *Bendis) corsSettings() CORSOptions {
+	options := CORSOptions{
+		AllowedOrigins: envList("CORS_ALLOWED_ORIGINS"),
+		AllowedMethods: envList("CORS_ALLOWED_METHODS"),
+		AllowedHeaders: envList("CORS_ALLOWED_HEADERS"),
+		ExposedHeaders: envList("CORS_EXPOSED_HEADERS"),
+		Paths:          envList("CORS_PATHS"),
+	}
+
+	options.AllowCredentials, _ = strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS"))
+	options.MaxAge, _ = strconv.Atoi(os.Getenv("CORS_MAX_AGE"))
+
+	return b.checkCORS(options)
+}
+
+// checkCORS turns off credentials for options that allow any origin, as every site could then
+// read the responses meant for the user
+func (b *Bendis) checkCORS(options CORSOptions) CORSOptions {
+	if options.AllowCredentials && options.allowsAnyOrigin() {
+		b.ErrorLog.Println("CORS credentials are not allowed along with the origin *; list the origins instead")
+		options.AllowCredentials = false
+	}
+	return options
+}
+
+// CORS is middleware that applies the CORS settings from .env. It does nothing if
+// CORS_ALLOWED_ORIGINS is empty, so it is always installed; use CORSWith for route groups that need
+// other settings.
+func (b *Bendis) CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the settings are read when the request comes in, as routes are set up before New reads .env
+		options := b.config.cors
+		if len(options.AllowedOrigins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		handleCORS(w, r, next, options)
+	})
+}
+
+// CORSWith returns middleware that applies options, for a route group, e.g.
+//
+//	r.Route("/api", func(r chi.Router) {
+//		r.Use(app.CORSWith(bendis.CORSOptions{AllowedOrigins: []string{"https://app.example.com"}}))
+//	})
+func (b *Bendis) CORSWith(options CORSOptions) func(http.Handler) http.Handler {
+	options = b.checkCORS(options)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handleCORS(w, r, next, options)
+		})
+	}
+}
+
+// handleCORS adds the CORS headers for an allowed origin, and answers preflight requests itself
+func handleCORS(w http.ResponseWriter, r *http.Request, next http.Handler, options CORSOptions) {
+	if !options.appliesTo(r.URL.Path) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	// the answer depends on these request headers, so caches must keep an answer for each
+	w.Header().Add("Vary", "Origin")
+	if preflight {
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if origin == "" || !options.allowsOrigin(origin) {
+		if preflight {
+			// without CORS headers the browser refuses the actual request
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	if options.allowsAnyOrigin() {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if options.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !preflight {
+		if len(options.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(options.ExposedHeaders, ", "))
+		}
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !options.allowsMethod(method) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	if !options.allowsHeaders(requested) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(options.methods(), ", "))
+	if requested != "" {
+		// echoing the requested headers also covers AllowedHeaders *, which browsers ignore with credentials
+		w.Header().Set("Access-Control-Allow-Headers", requested)
+	}
+	if options.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(options.MaxAge))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (o CORSOptions) appliesTo(urlPath string) bool {
+	return len(o.Paths) == 0 || matchPath(o.Paths, urlPath)
+}
+
+func (o CORSOptions) allowsAnyOrigin() bool {
+	for _, allowed := range o.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (o CORSOptions) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range o.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+
+		// https://*.example.com matches subdomains, but not example.com itself or example.com.evil.com
+		if i := strings.Index(allowed, "*"); i >= 0 {
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+				!strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (o CORSOptions) methods() []string {
+	if len(o.AllowedMethods) == 0 {
+		return defaultCORSMethods
+	}
+	return o.AllowedMethods
+}
+
+func (o CORSOptions) allowsMethod(method string) bool {
+	for _, allowed := range o.methods() {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o CORSOptions) allowsHeaders(requested string) bool {
+	allowedHeaders := o.AllowedHeaders
+	if len(allowedHeaders) == 0 {
+		allowedHeaders = defaultCORSHeaders
+	}
+
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+
+		allowed := false
+		for _, a := range allowedHeaders {
+			if a == "*" || strings.EqualFold(a, header) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
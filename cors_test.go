@@ -0,0 +1,181 @@
+package bendis
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSOptions_AllowsOrigin(t *testing.T) {
+	options := CORSOptions{AllowedOrigins: []string{"https://app.example.com", "https://*.example.org", "http://localhost:*"}}
+
+	var tests = []struct {
+		origin   string
+		expected bool
+	}{
+		{"https://app.example.com", true},
+		{"HTTPS://APP.EXAMPLE.COM", true},
+		{"http://app.example.com", false},
+		{"https://app.example.com.evil.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://.example.org", false},
+		{"https://example.org.evil.com", false},
+		{"https://evil.com/.example.org", false},
+		{"https://evil.com:443.example.org", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:", false},
+		{"http://localhost.evil.com:3000", false},
+	}
+
+	for _, e := range tests {
+		if allowed := options.allowsOrigin(e.origin); allowed != e.expected {
+			t.Errorf("%s: expected %t, got %t", e.origin, e.expected, allowed)
+		}
+	}
+
+	if !(CORSOptions{AllowedOrigins: []string{"*"}}).allowsOrigin("https://anything.com") {
+		t.Error("expected * to allow any origin")
+	}
+}
+
+func TestBendis_CORSWith(t *testing.T) {
+	b := &Bendis{ErrorLog: log.New(io.Discard, "", 0)}
+
+	var tests = []struct {
+		name     string
+		options  CORSOptions
+		method   string
+		path     string
+		headers  map[string]string
+		status   int
+		expected map[string]string
+	}{
+		{
+			"allowed origin",
+			CORSOptions{AllowedOrigins: []string{"https://app.example.com"}, ExposedHeaders: []string{"X-Total"}},
+			"GET", "/", map[string]string{"Origin": "https://app.example.com"},
+			http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://app.example.com", "Access-Control-Expose-Headers": "X-Total"},
+		},
+		{
+			"other origin",
+			CORSOptions{AllowedOrigins: []string{"https://app.example.com"}},
+			"GET", "/", map[string]string{"Origin": "https://evil.com"},
+			http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			"any origin",
+			CORSOptions{AllowedOrigins: []string{"*"}},
+			"GET", "/", map[string]string{"Origin": "https://evil.com"},
+			http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": ""},
+		},
+		{
+			"any origin with credentials",
+			CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			"GET", "/", map[string]string{"Origin": "https://evil.com"},
+			http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": ""},
+		},
+		{
+			"credentials",
+			CORSOptions{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+			"GET", "/", map[string]string{"Origin": "https://app.example.com"},
+			http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://app.example.com", "Access-Control-Allow-Credentials": "true"},
+		},
+		{
+			"preflight",
+			CORSOptions{AllowedOrigins: []string{"https://*.example.com"}, MaxAge: 600},
+			"OPTIONS", "/", map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "Content-Type"},
+			http.StatusNoContent,
+			map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "GET, HEAD, POST, PUT, PATCH, DELETE",
+				"Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			"preflight for a method not allowed",
+			CORSOptions{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}},
+			"OPTIONS", "/", map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"},
+			http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+		{
+			"preflight for a header not allowed",
+			CORSOptions{AllowedOrigins: []string{"*"}},
+			"OPTIONS", "/", map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Secret"},
+			http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Headers": ""},
+		},
+		{
+			"preflight from another origin",
+			CORSOptions{AllowedOrigins: []string{"https://app.example.com"}},
+			"OPTIONS", "/", map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "GET"},
+			http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			"path outside the options",
+			CORSOptions{AllowedOrigins: []string{"*"}, Paths: []string{"/api/*"}},
+			"GET", "/admin", map[string]string{"Origin": "https://app.example.com"},
+			http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""},
+		},
+		{
+			"path inside the options",
+			CORSOptions{AllowedOrigins: []string{"*"}, Paths: []string{"/api/*"}},
+			"GET", "/api/users", map[string]string{"Origin": "https://app.example.com"},
+			http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Vary": "Origin"},
+		},
+	}
+
+	for _, e := range tests {
+		handler := b.CORSWith(e.options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		r := httptest.NewRequest(e.method, e.path, nil)
+		for key, value := range e.headers {
+			r.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, w.Code)
+		}
+		for header, value := range e.expected {
+			if got := w.Header().Get(header); got != value {
+				t.Errorf("%s: expected %s to be %q, got %q", e.name, header, value, got)
+			}
+		}
+	}
+}
+
+func TestBendis_CORSSettings(t *testing.T) {
+	var logged bytes.Buffer
+	b := &Bendis{ErrorLog: log.New(&logged, "", 0)}
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+
+	if b.corsSettings().AllowCredentials {
+		t.Error("expected credentials to be turned off for the origin *")
+	}
+	if logged.Len() == 0 {
+		t.Error("expected an error to be logged")
+	}
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com")
+	if !b.corsSettings().AllowCredentials {
+		t.Error("expected credentials for a listed origin")
+	}
+}
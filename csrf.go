@@ -0,0 +1,114 @@
+package bendis
+
+import (
+	"github.com/justinas/nosurf"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type csrfConfig struct {
+	exempt        []string // path globs
+	exemptMethods []string
+	exemptBearer  bool
+	sameSite      http.SameSite
+	cookiePath    string
+	header        string
+}
+
+// csrfSettings reads CSRF_EXEMPT (path globs, /api/* by default), CSRF_EXEMPT_METHODS,
+// CSRF_EXEMPT_BEARER, CSRF_SAMESITE (strict, lax or none), CSRF_COOKIE_PATH and CSRF_HEADER.
+// Exempting requests with a bearer token is opt in, as it is only safe if nothing in front of the
+// application, such as a proxy turning a cookie into the header, makes browsers send one on their own.
+func (b *Bendis) csrfSettings() csrfConfig {
+	settings := csrfConfig{
+		exempt:        []string{"/api/*"},
+		exemptMethods: envList("CSRF_EXEMPT_METHODS"),
+		sameSite:      sameSiteMode(os.Getenv("CSRF_SAMESITE"), http.SameSiteStrictMode),
+		cookiePath:    os.Getenv("CSRF_COOKIE_PATH"),
+		header:        os.Getenv("CSRF_HEADER"),
+	}
+
+	if _, ok := os.LookupEnv("CSRF_EXEMPT"); ok {
+		settings.exempt = envList("CSRF_EXEMPT")
+	}
+
+	settings.exemptBearer, _ = strconv.ParseBool(os.Getenv("CSRF_EXEMPT_BEARER"))
+
+	if settings.cookiePath == "" {
+		settings.cookiePath = "/"
+	}
+
+	return settings
+}
+
+// sameSiteMode parses strict, lax or none, and returns def for anything else
+func sameSiteMode(mode string, def http.SameSite) http.SameSite {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return def
+}
+
+// csrfExempt reports whether r needs no CSRF token: its path or method is exempt, or, with
+// CSRF_EXEMPT_BEARER, it authenticates with a bearer token, which browsers do not send on their own
+// the way they do cookies
+func (b *Bendis) csrfExempt(r *http.Request) bool {
+	settings := b.config.csrf
+
+	if matchPath(settings.exempt, r.URL.Path) {
+		return true
+	}
+
+	for _, method := range settings.exemptMethods {
+		if strings.EqualFold(method, r.Method) {
+			return true
+		}
+	}
+
+	return settings.exemptBearer && strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// csrfFailure answers requests without a valid CSRF token with 403: problem+json for clients that
+// prefer JSON, the errors/csrf view if there is one, or the error page for 403
+func (b *Bendis) csrfFailure(w http.ResponseWriter, r *http.Request) {
+	reason := nosurf.Reason(r)
+	b.InfoLog.Println("CSRF check failed for", r.Method, r.URL.Path+":", reason)
+
+	if wantsJSON(r) {
+		detail := "The CSRF token is missing or invalid"
+		if reason == nosurf.ErrNoReferer || reason == nosurf.ErrBadReferer {
+			detail = "The request did not come from this site"
+		}
+
+		err := b.WriteProblem(w, r, http.StatusForbidden, detail)
+		if err != nil {
+			b.ErrorLog.Println(err)
+		}
+		return
+	}
+
+	if b.Render != nil && b.errorViewExists("errors/csrf") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		err := b.Render.Page(w, r, "errors/csrf", nil, nil)
+		if err != nil {
+			b.ErrorLog.Println(err)
+		}
+		return
+	}
+
+	b.ErrorResponse(w, r, http.StatusForbidden)
+}
+
+// CSRFToken returns the CSRF token for r, for handlers that hand it to scripts, e.g. in JSON. Pages
+// get it as CSRFToken.
+func CSRFToken(r *http.Request) string {
+	return nosurf.Token(r)
+}
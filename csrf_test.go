@@ -0,0 +1,101 @@
+package bendis
+
+import (
+	"github.com/justinas/nosurf"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBendis_NoSurf(t *testing.T) {
+	var tests = []struct {
+		name        string
+		settings    csrfConfig
+		method      string
+		path        string
+		headers     map[string]string
+		tokenHeader string // the header the token is sent in; empty sends no token
+		status      int
+		contentType string
+	}{
+		{"safe method", csrfConfig{}, "GET", "/", nil, "", http.StatusOK, ""},
+		{"no token", csrfConfig{}, "POST", "/", nil, "", http.StatusForbidden, "text/html; charset=utf-8"},
+		{"no token, json", csrfConfig{}, "POST", "/", map[string]string{"Accept": "application/json"}, "", http.StatusForbidden, "application/problem+json"},
+		{"token", csrfConfig{}, "POST", "/", nil, nosurf.HeaderName, http.StatusOK, ""},
+		{"exempt path", csrfConfig{exempt: []string{"/api/*"}}, "POST", "/api/users", nil, "", http.StatusOK, ""},
+		{"path outside the exempt ones", csrfConfig{exempt: []string{"/api/*"}}, "POST", "/apiary", nil, "", http.StatusForbidden, ""},
+		{"exempt method", csrfConfig{exemptMethods: []string{"post"}}, "POST", "/", nil, "", http.StatusOK, ""},
+		{"bearer token", csrfConfig{}, "POST", "/", map[string]string{"Authorization": "Bearer abc"}, "", http.StatusForbidden, ""},
+		{"bearer token exempt", csrfConfig{exemptBearer: true}, "POST", "/", map[string]string{"Authorization": "Bearer abc"}, "", http.StatusOK, ""},
+		{"basic auth with bearer exempt", csrfConfig{exemptBearer: true}, "POST", "/", map[string]string{"Authorization": "Basic abc"}, "", http.StatusForbidden, ""},
+		{"custom header", csrfConfig{header: "X-XSRF-Token"}, "POST", "/", nil, "X-XSRF-Token", http.StatusOK, ""},
+		{"custom header not set", csrfConfig{}, "POST", "/", nil, "X-XSRF-Token", http.StatusForbidden, ""},
+	}
+
+	for _, e := range tests {
+		b := &Bendis{
+			InfoLog:  log.New(io.Discard, "", 0),
+			ErrorLog: log.New(io.Discard, "", 0),
+			config:   config{csrf: e.settings},
+		}
+
+		var token string
+		handler := b.NoSurf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token = nosurf.Token(r)
+		}))
+
+		// a first visit hands out the cookie and the token that goes with it
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		cookies := w.Result().Cookies()
+		if len(cookies) == 0 || token == "" {
+			t.Errorf("%s: expected a CSRF cookie and token", e.name)
+			continue
+		}
+
+		r := httptest.NewRequest(e.method, e.path, nil)
+		r.AddCookie(cookies[0])
+		for key, value := range e.headers {
+			r.Header.Set(key, value)
+		}
+		if e.tokenHeader != "" {
+			r.Header.Set(e.tokenHeader, token)
+		}
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, w.Code)
+		}
+		if e.contentType != "" && w.Header().Get("Content-Type") != e.contentType {
+			t.Errorf("%s: expected content type %s, got %s", e.name, e.contentType, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestBendis_CSRFSettings(t *testing.T) {
+	t.Setenv("CSRF_EXEMPT_METHODS", "PUT")
+	t.Setenv("CSRF_SAMESITE", "lax")
+
+	settings := (&Bendis{}).csrfSettings()
+	if len(settings.exempt) != 1 || settings.exempt[0] != "/api/*" {
+		t.Errorf("expected /api/* to be exempt by default, got %v", settings.exempt)
+	}
+	if settings.exemptBearer {
+		t.Error("expected bearer tokens not to be exempt by default")
+	}
+	if len(settings.exemptMethods) != 1 || settings.sameSite != http.SameSiteLaxMode || settings.cookiePath != "/" {
+		t.Errorf("unexpected settings: %+v", settings)
+	}
+
+	t.Setenv("CSRF_EXEMPT", "")
+	t.Setenv("CSRF_EXEMPT_BEARER", "true")
+
+	settings = (&Bendis{}).csrfSettings()
+	if len(settings.exempt) != 0 || !settings.exemptBearer {
+		t.Errorf("expected nothing exempt but bearer tokens, got %+v", settings)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	_ "github.com/jackc/pgconn"
 	_ "github.com/jackc/pgx/v4"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func (b *Bendis) OpenDB(dbType, dsn string) (*sql.DB, error) {
@@ -0,0 +1,172 @@
+package bendis
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// fieldEncryption encrypts EncryptedString and EncryptedJSON columns. Scan and Value have no way to
+// reach the application, so New sets it once for the whole process.
+var fieldEncryption *Encryption
+
+// blindIndexKey is the HMAC key for BlindIndex
+var blindIndexKey []byte
+
+var errNoFieldEncryption = errors.New("no encryption configured for encrypted fields")
+
+var errNoBlindIndexKey = errors.New("no key for blind indexes; set KEY or BLIND_INDEX_KEY")
+
+// SetFieldEncryption sets the keyring used by encrypted model fields, and the key used for blind
+// indexes. New calls it with the application keys; call it yourself in tests or tools that do not
+// create a Bendis application. An empty index key is refused, since anyone could compute the blind
+// indexes, and BlindIndex returns an error until there is one.
+func SetFieldEncryption(e *Encryption, indexKey []byte) error {
+	fieldEncryption = e
+	if len(indexKey) == 0 {
+		blindIndexKey = nil
+		return errNoBlindIndexKey
+	}
+
+	blindIndexKey = indexKey
+	return nil
+}
+
+// EncryptedString is a string that is stored encrypted, e.g. a national ID or phone number. Use it
+// as the type of a model field; the column must be a text column.
+type EncryptedString string
+
+// Value encrypts the string for the database. Empty strings are stored as they are.
+func (s EncryptedString) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	if fieldEncryption == nil {
+		return nil, errNoFieldEncryption
+	}
+
+	return fieldEncryption.Encrypt(string(s))
+}
+
+// Scan decrypts a value read from the database
+func (s *EncryptedString) Scan(src interface{}) error {
+	plaintext, err := decryptColumn(src)
+	if err != nil {
+		return err
+	}
+
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// String returns the decrypted string
+func (s EncryptedString) String() string {
+	return string(s)
+}
+
+// EncryptedJSON holds a JSON document that is stored encrypted. Create one with NewEncryptedJSON,
+// and read it with Unmarshal.
+type EncryptedJSON []byte
+
+// NewEncryptedJSON marshals v into an EncryptedJSON
+func NewEncryptedJSON(v interface{}) (EncryptedJSON, error) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Unmarshal decodes the JSON document into v
+func (j EncryptedJSON) Unmarshal(v interface{}) error {
+	return json.Unmarshal(j, v)
+}
+
+// MarshalJSON writes the document as it is, rather than as a base64 encoded byte slice
+func (j EncryptedJSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON keeps a copy of the document
+func (j *EncryptedJSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
+
+// Value encrypts the document for the database. An empty document is stored as NULL.
+func (j EncryptedJSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+
+	if fieldEncryption == nil {
+		return nil, errNoFieldEncryption
+	}
+
+	return fieldEncryption.Encrypt(string(j))
+}
+
+// Scan decrypts a value read from the database
+func (j *EncryptedJSON) Scan(src interface{}) error {
+	if src == nil {
+		*j = nil
+		return nil
+	}
+
+	plaintext, err := decryptColumn(src)
+	if err != nil {
+		return err
+	}
+
+	*j = EncryptedJSON(plaintext)
+	return nil
+}
+
+// BlindIndex returns a keyed hash of value, to store in a column next to an encrypted one, so that
+// rows can be looked up by the exact value without decrypting them, e.g. with
+// collection.Find(up.Cond{"phone_index": index}) for the index of phone. Normalize values, e.g.
+// strip spaces from phone numbers, before storing and looking them up. It returns an error if
+// SetFieldEncryption was not given a key.
+func BlindIndex(value string) (string, error) {
+	if len(blindIndexKey) == 0 {
+		return "", errNoBlindIndexKey
+	}
+
+	mac := hmac.New(sha256.New, blindIndexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// decryptColumn decrypts a text or blob column value. NULL and empty values decrypt to "".
+func decryptColumn(src interface{}) (string, error) {
+	var cryptoText string
+	switch v := src.(type) {
+	case nil:
+		return "", nil
+	case string:
+		cryptoText = v
+	case []byte:
+		cryptoText = string(v)
+	default:
+		return "", fmt.Errorf("cannot decrypt a column of type %T", src)
+	}
+
+	if cryptoText == "" {
+		return "", nil
+	}
+
+	if fieldEncryption == nil {
+		return "", errNoFieldEncryption
+	}
+
+	return fieldEncryption.Decrypt(cryptoText)
+}
@@ -0,0 +1,143 @@
+package bendis
+
+import (
+	"errors"
+	"testing"
+)
+
+// withFieldEncryption sets the field encryption for a test, and restores the previous one after it
+func withFieldEncryption(t *testing.T, e *Encryption, indexKey []byte) {
+	previous, previousIndexKey := fieldEncryption, blindIndexKey
+	t.Cleanup(func() {
+		fieldEncryption, blindIndexKey = previous, previousIndexKey
+	})
+
+	_ = SetFieldEncryption(e, indexKey)
+}
+
+func TestEncryptedString(t *testing.T) {
+	withFieldEncryption(t, &Encryption{Key: []byte(testKey)}, []byte("index key"))
+
+	value, err := EncryptedString("123-45-6789").Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value == "123-45-6789" {
+		t.Error("the value was stored in plain text")
+	}
+
+	var s EncryptedString
+	for _, src := range []interface{}{value, []byte(value.(string))} {
+		if err := s.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if s.String() != "123-45-6789" {
+			t.Errorf("expected 123-45-6789, got %s", s)
+		}
+	}
+
+	if value, _ := EncryptedString("").Value(); value != "" {
+		t.Error("an empty string was encrypted")
+	}
+
+	for _, src := range []interface{}{nil, ""} {
+		if err := s.Scan(src); err != nil || s != "" {
+			t.Errorf("expected %v to scan as an empty string, got %q, %v", src, s, err)
+		}
+	}
+
+	if err := s.Scan(42); err == nil {
+		t.Error("expected an error scanning an int")
+	}
+}
+
+func TestEncryptedJSON(t *testing.T) {
+	withFieldEncryption(t, &Encryption{Key: []byte(testKey)}, []byte("index key"))
+
+	type address struct {
+		Street string
+		City   string
+	}
+
+	j, err := NewEncryptedJSON(address{Street: "Main Street 1", City: "Springfield"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := j.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var scanned EncryptedJSON
+	if err := scanned.Scan(value); err != nil {
+		t.Fatal(err)
+	}
+
+	var a address
+	if err := scanned.Unmarshal(&a); err != nil {
+		t.Fatal(err)
+	}
+	if a.City != "Springfield" {
+		t.Errorf("unexpected address %+v", a)
+	}
+
+	if value, _ := EncryptedJSON(nil).Value(); value != nil {
+		t.Error("an empty document was not stored as NULL")
+	}
+
+	if err := scanned.Scan(nil); err != nil || scanned != nil {
+		t.Error("NULL did not scan as an empty document")
+	}
+}
+
+func TestEncryptedFields_NoEncryption(t *testing.T) {
+	withFieldEncryption(t, nil, []byte("index key"))
+
+	if _, err := EncryptedString("secret").Value(); !errors.Is(err, errNoFieldEncryption) {
+		t.Error("expected errNoFieldEncryption, got", err)
+	}
+
+	var s EncryptedString
+	if err := s.Scan("v2:abcd:xyz"); !errors.Is(err, errNoFieldEncryption) {
+		t.Error("expected errNoFieldEncryption, got", err)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	withFieldEncryption(t, &Encryption{Key: []byte(testKey)}, []byte("index key"))
+
+	blindIndex := func(value string) string {
+		index, err := BlindIndex(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return index
+	}
+
+	index := blindIndex("+1 555 0100")
+	if index != blindIndex("+1 555 0100") {
+		t.Error("the blind index of a value changed")
+	}
+	if index == blindIndex("+1 555 0101") {
+		t.Error("two values have the same blind index")
+	}
+
+	_ = SetFieldEncryption(fieldEncryption, []byte("other key"))
+	if index == blindIndex("+1 555 0100") {
+		t.Error("the blind index does not depend on the key")
+	}
+}
+
+func TestBlindIndex_NoKey(t *testing.T) {
+	withFieldEncryption(t, nil, nil)
+
+	if err := SetFieldEncryption(&Encryption{Key: []byte(testKey)}, nil); err == nil {
+		t.Error("expected an error for an empty index key")
+	}
+
+	index, err := BlindIndex("+1 555 0100")
+	if !errors.Is(err, errNoBlindIndexKey) || index != "" {
+		t.Errorf("expected errNoBlindIndexKey, got %q and %v", index, err)
+	}
+}
@@ -0,0 +1,177 @@
+package bendis
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// encryptionVersion prefixes values encrypted with AES-GCM. Values without it were encrypted with
+// AES-CFB by earlier versions, and are still decrypted with LegacyKey.
+const encryptionVersion = "v2"
+
+var errCiphertextTooShort = errors.New("ciphertext too short")
+
+// Encryption encrypts and decrypts strings with AES-GCM. Encrypted values look like
+// v2:<key id>:<base64 nonce and ciphertext>, where the key id identifies the key used, so that
+// values encrypted before the key was rotated can still be decrypted with PreviousKeys.
+type Encryption struct {
+	Key          []byte   // the key new values are encrypted with: 16, 24 or 32 bytes
+	PreviousKeys [][]byte // keys that were in use before, newest first
+	LegacyKey    []byte   // the key values without a version were encrypted with; defaults to Key
+}
+
+// Encrypter returns an Encryption using the application KEY, the keys in PREVIOUS_KEYS, and
+// LEGACY_KEY for values from before they were versioned
+func (b *Bendis) Encrypter() *Encryption {
+	return &Encryption{
+		Key:          []byte(b.EncryptionKey),
+		PreviousKeys: b.config.previousKeys,
+		LegacyKey:    b.config.legacyKey,
+	}
+}
+
+func (e *Encryption) Encrypt(text string) (string, error) {
+	gcm, err := newGCM(e.Key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	prefix := encryptionVersion + ":" + keyID(e.Key)
+	sealed := gcm.Seal(nonce, nonce, []byte(text), []byte(prefix))
+
+	return prefix + ":" + base64.URLEncoding.EncodeToString(sealed), nil
+}
+
+func (e *Encryption) Decrypt(cryptoText string) (string, error) {
+	parts := strings.SplitN(cryptoText, ":", 3)
+	if len(parts) == 1 {
+		return e.decryptLegacy(cryptoText)
+	}
+
+	if len(parts) != 3 || parts[0] != encryptionVersion {
+		return "", errors.New("unknown encryption format")
+	}
+
+	key := e.key(parts[1])
+	if key == nil {
+		return "", fmt.Errorf("no key with id %s", parts[1])
+	}
+
+	sealed, err := base64.URLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize()+gcm.Overhead() {
+		return "", errCiphertextTooShort
+	}
+
+	nonce := sealed[:gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, sealed[gcm.NonceSize():], []byte(parts[0]+":"+parts[1]))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// Reencrypt decrypts cryptoText with whichever key it was encrypted with, and encrypts it again with
+// the current key. Use it to move stored values off a key before removing it from PreviousKeys.
+func (e *Encryption) Reencrypt(cryptoText string) (string, error) {
+	plaintext, err := e.Decrypt(cryptoText)
+	if err != nil {
+		return "", err
+	}
+
+	return e.Encrypt(plaintext)
+}
+
+// NeedsReencrypt reports whether cryptoText was encrypted with an old format or an old key
+func (e *Encryption) NeedsReencrypt(cryptoText string) bool {
+	return !strings.HasPrefix(cryptoText, encryptionVersion+":"+keyID(e.Key)+":")
+}
+
+// decryptLegacy decrypts a value encrypted with AES-CFB. Those values carry no key id, and CFB is not
+// authenticated, so a wrong key gives garbage rather than an error; rather than guessing between
+// keys, they are only decrypted with LegacyKey, the key that was in use before values were
+// versioned. Legacy values should be moved to the current format with Reencrypt.
+func (e *Encryption) decryptLegacy(cryptoText string) (string, error) {
+	ciphertext, err := base64.URLEncoding.DecodeString(cryptoText)
+	if err != nil {
+		return "", err
+	}
+
+	if len(ciphertext) < aes.BlockSize {
+		return "", errCiphertextTooShort
+	}
+
+	key := e.LegacyKey
+	if len(key) == 0 {
+		key = e.Key
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := make([]byte, len(ciphertext)-aes.BlockSize)
+	stream := cipher.NewCFBDecrypter(block, ciphertext[:aes.BlockSize])
+	stream.XORKeyStream(plaintext, ciphertext[aes.BlockSize:])
+
+	// only strings were encrypted, so anything else was encrypted with another key
+	if !utf8.Valid(plaintext) {
+		return "", errors.New("value could not be decrypted with the legacy key")
+	}
+
+	return string(plaintext), nil
+}
+
+// key returns the current or previous key with the given id, or nil
+func (e *Encryption) key(id string) []byte {
+	if keyID(e.Key) == id {
+		return e.Key
+	}
+
+	for _, key := range e.PreviousKeys {
+		if keyID(key) == id {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// keyID identifies a key without revealing it: the first four bytes of its SHA-256 hash, in hex
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
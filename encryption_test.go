@@ -0,0 +1,148 @@
+package bendis
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+)
+
+const (
+	testKey    = "0123456789abcdef0123456789abcdef"
+	testOldKey = "abcdef0123456789abcdef0123456789"
+)
+
+// encryptLegacy encrypts text the way Encryption did before values were versioned, with AES-CFB
+func encryptLegacy(t *testing.T, key, text string) string {
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext := make([]byte, aes.BlockSize+len(text))
+	iv := ciphertext[:aes.BlockSize]
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := cipher.NewCFBEncrypter(block, iv)
+	stream.XORKeyStream(ciphertext[aes.BlockSize:], []byte(text))
+
+	return base64.URLEncoding.EncodeToString(ciphertext)
+}
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	e := Encryption{Key: []byte(testKey)}
+
+	encrypted, err := e.Encrypt("my secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(encrypted, encryptionVersion+":"+keyID(e.Key)+":") {
+		t.Errorf("unexpected format %s", encrypted)
+	}
+
+	decrypted, err := e.Decrypt(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != "my secret" {
+		t.Errorf("expected my secret, got %s", decrypted)
+	}
+
+	again, _ := e.Encrypt("my secret")
+	if again == encrypted {
+		t.Error("encrypting the same value twice gave the same result")
+	}
+
+	// flip a byte of the ciphertext
+	parts := strings.SplitN(encrypted, ":", 3)
+	sealed, _ := base64.URLEncoding.DecodeString(parts[2])
+	sealed[len(sealed)-1] ^= 1
+	tampered := parts[0] + ":" + parts[1] + ":" + base64.URLEncoding.EncodeToString(sealed)
+	if _, err := e.Decrypt(tampered); err == nil {
+		t.Error("decrypted a tampered value")
+	}
+}
+
+func TestEncryption_Rotation(t *testing.T) {
+	old := Encryption{Key: []byte(testOldKey)}
+	encrypted, err := old.Encrypt("rotated")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := Encryption{Key: []byte(testKey), PreviousKeys: [][]byte{[]byte(testOldKey)}}
+
+	decrypted, err := e.Decrypt(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != "rotated" {
+		t.Errorf("expected rotated, got %s", decrypted)
+	}
+
+	if !e.NeedsReencrypt(encrypted) {
+		t.Error("a value encrypted with a previous key does not need to be reencrypted")
+	}
+
+	reencrypted, err := e.Reencrypt(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.NeedsReencrypt(reencrypted) {
+		t.Error("a reencrypted value still needs to be reencrypted")
+	}
+
+	withoutOldKey := Encryption{Key: []byte(testKey)}
+	if _, err := withoutOldKey.Decrypt(encrypted); err == nil {
+		t.Error("decrypted a value whose key is gone")
+	}
+	if decrypted, err := withoutOldKey.Decrypt(reencrypted); err != nil || decrypted != "rotated" {
+		t.Error("could not decrypt the reencrypted value:", err)
+	}
+}
+
+func TestEncryption_Legacy(t *testing.T) {
+	var tests = []struct {
+		name      string
+		key       string
+		encryptor Encryption
+	}{
+		{"current key", testKey, Encryption{Key: []byte(testKey)}},
+		{"legacy key", testOldKey, Encryption{Key: []byte(testKey), LegacyKey: []byte(testOldKey)}},
+		{"legacy key among the previous ones", testOldKey, Encryption{Key: []byte(testKey), PreviousKeys: [][]byte{[]byte(testOldKey)}, LegacyKey: []byte(testOldKey)}},
+	}
+
+	for _, e := range tests {
+		legacy := encryptLegacy(t, e.key, "a value from before the upgrade, with ümlauts")
+
+		decrypted, err := e.encryptor.Decrypt(legacy)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+		if decrypted != "a value from before the upgrade, with ümlauts" {
+			t.Errorf("%s: unexpected value %q", e.name, decrypted)
+		}
+
+		if !e.encryptor.NeedsReencrypt(legacy) {
+			t.Errorf("%s: a legacy value does not need to be reencrypted", e.name)
+		}
+	}
+
+	unknown := Encryption{Key: []byte("fedcba9876543210fedcba9876543210")}
+	if _, err := unknown.Decrypt(encryptLegacy(t, testKey, "a value from before the upgrade")); err == nil {
+		t.Error("decrypted a legacy value without its key")
+	}
+
+	// previous keys are not tried, as a wrong key may still give valid UTF-8
+	previous := Encryption{Key: []byte(testKey), PreviousKeys: [][]byte{[]byte(testOldKey)}}
+	if _, err := previous.Decrypt(encryptLegacy(t, testOldKey, "a value from before the upgrade")); err == nil {
+		t.Error("decrypted a legacy value with a previous key that is not the legacy key")
+	}
+}
@@ -0,0 +1,182 @@
+package encryptedfilesystem
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/zgoerbe/bendis/filesystems"
+	"io"
+	"os"
+	"path"
+)
+
+// magic marks a file written by Encrypted, followed by a one byte format version
+var magic = []byte("BENC")
+
+const formatVersion = 1
+
+// Encrypted wraps any file system, encrypting files with AES-GCM before they are handed to Put, and
+// decrypting them after Get. Every file starts with a small header holding the id of the key that
+// was used and the nonce, so keys can be rotated by adding a new one to Keys and changing KeyID.
+// Files are encrypted in memory, so this is meant for documents rather than multi-GB media.
+type Encrypted struct {
+	FS      filesystems.FS
+	Keys    map[string][]byte
+	KeyID   string
+	TempDir string
+}
+
+// Put encrypts fileName into a temporary file with the same name, and stores that on the wrapped file system
+func (e *Encrypted) Put(fileName, folder string) error {
+	plaintext, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := e.seal(plaintext)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp(e.TempDir, "bendis-encrypted-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	encryptedFile := fmt.Sprintf("%s/%s", tmpDir, path.Base(fileName))
+	err = os.WriteFile(encryptedFile, sealed, 0600)
+	if err != nil {
+		return err
+	}
+
+	return e.FS.Put(encryptedFile, folder)
+}
+
+// Get retrieves items from the wrapped file system into destination, and decrypts them in place
+func (e *Encrypted) Get(destination string, items ...string) error {
+	err := e.FS.Get(destination, items...)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		localFile := fmt.Sprintf("%s/%s", destination, path.Base(item))
+
+		sealed, err := os.ReadFile(localFile)
+		if err != nil {
+			return err
+		}
+
+		plaintext, err := e.open(sealed)
+		if err != nil {
+			_ = os.Remove(localFile)
+			return fmt.Errorf("%s: %w", item, err)
+		}
+
+		err = os.WriteFile(localFile, plaintext, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List lists the wrapped file system. Sizes include the encryption header and tag.
+func (e *Encrypted) List(prefix string) ([]filesystems.Listing, error) {
+	return e.FS.List(prefix)
+}
+
+// Walk lists every file below prefix on the wrapped file system, with Walk if it has it
+func (e *Encrypted) Walk(prefix string) ([]filesystems.Listing, error) {
+	if w, ok := e.FS.(filesystems.Walker); ok {
+		return w.Walk(prefix)
+	}
+	return e.FS.List(prefix)
+}
+
+// Delete deletes items from the wrapped file system
+func (e *Encrypted) Delete(itemsToDelete []string) bool {
+	return e.FS.Delete(itemsToDelete)
+}
+
+// seal encrypts plaintext, and returns header || ciphertext. The header is authenticated as well.
+func (e *Encrypted) seal(plaintext []byte) ([]byte, error) {
+	key, ok := e.Keys[e.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q not found", e.KeyID)
+	}
+
+	if len(e.KeyID) > 255 {
+		return nil, errors.New("key id must not be longer than 255 bytes")
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{}, magic...)
+	header = append(header, formatVersion, byte(len(e.KeyID)))
+	header = append(header, e.KeyID...)
+	header = append(header, nonce...)
+
+	return gcm.Seal(header, nonce, plaintext, header), nil
+}
+
+// open parses the header written by seal, and decrypts the rest with the key it names
+func (e *Encrypted) open(sealed []byte) ([]byte, error) {
+	if len(sealed) < len(magic)+2 || !bytes.Equal(sealed[:len(magic)], magic) {
+		return nil, errors.New("file is not encrypted")
+	}
+
+	if sealed[len(magic)] != formatVersion {
+		return nil, fmt.Errorf("unsupported encryption format version %d", sealed[len(magic)])
+	}
+
+	idStart := len(magic) + 2
+	idEnd := idStart + int(sealed[len(magic)+1])
+	if len(sealed) < idEnd {
+		return nil, errors.New("encrypted file is truncated")
+	}
+
+	keyID := string(sealed[idStart:idEnd])
+	key, ok := e.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q not found", keyID)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	headerEnd := idEnd + gcm.NonceSize()
+	if len(sealed) < headerEnd+gcm.Overhead() {
+		return nil, errors.New("encrypted file is truncated")
+	}
+
+	header := sealed[:headerEnd]
+	nonce := sealed[idEnd:headerEnd]
+
+	return gcm.Open(nil, nonce, sealed[headerEnd:], header)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,95 @@
+package encryptedfilesystem
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/zgoerbe/bendis/filesystems"
+)
+
+// diskFS stores files in a local folder, so we can look at what actually reached the file system
+type diskFS struct {
+	root string
+}
+
+func (d *diskFS) Put(fileName, folder string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fmt.Sprintf("%s/%s", d.root, path.Base(fileName)), data, 0644)
+}
+
+func (d *diskFS) Get(destination string, items ...string) error {
+	for _, item := range items {
+		data, err := os.ReadFile(fmt.Sprintf("%s/%s", d.root, path.Base(item)))
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(fmt.Sprintf("%s/%s", destination, path.Base(item)), data, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *diskFS) List(prefix string) ([]filesystems.Listing, error) {
+	return nil, nil
+}
+
+func (d *diskFS) Delete(itemsToDelete []string) bool {
+	return true
+}
+
+func TestEncrypted_PutGet(t *testing.T) {
+	remote := &diskFS{root: t.TempDir()}
+	local := t.TempDir()
+	secret := []byte("document with a national id 1234")
+
+	err := os.WriteFile(local+"/doc.txt", secret, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc := Encrypted{
+		FS:    remote,
+		Keys:  map[string][]byte{"old": bytes.Repeat([]byte("a"), 32)},
+		KeyID: "old",
+	}
+
+	err = enc.Put(local+"/doc.txt", "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, _ := os.ReadFile(remote.root + "/doc.txt")
+	if bytes.Contains(stored, secret) {
+		t.Error("plaintext reached the wrapped file system")
+	}
+
+	// rotate the key; files written with the old key must still be readable
+	enc.Keys["new"] = bytes.Repeat([]byte("b"), 32)
+	enc.KeyID = "new"
+
+	download := t.TempDir()
+	err = enc.Get(download, "docs/doc.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := os.ReadFile(download + "/doc.txt")
+	if !bytes.Equal(got, secret) {
+		t.Errorf("expected %q, got %q", secret, got)
+	}
+
+	// tampering must be detected
+	stored[len(stored)-1] ^= 0xff
+	_ = os.WriteFile(remote.root+"/doc.txt", stored, 0644)
+	if err := enc.Get(download, "docs/doc.txt"); err == nil {
+		t.Error("expected an error for a tampered file")
+	}
+}
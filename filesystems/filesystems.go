@@ -10,6 +10,12 @@ type FS interface {
 	Delete(itemsToDelete []string) bool
 }
 
+// Walker is implemented by file systems whose List only returns the base names of the files directly
+// in a folder. Walk returns every file below prefix, in nested folders too, by its full path.
+type Walker interface {
+	Walk(prefix string) ([]Listing, error)
+}
+
 // Listing describes a file on a remote file system
 type Listing struct {
 	Etag         string
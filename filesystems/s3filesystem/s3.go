@@ -91,12 +91,27 @@ func (s *S3) List(prefix string) ([]filesystems.Listing, error) {
 	}))
 
 	svc := s3.New(sess)
-	input := &s3.ListObjectsInput{
+	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.Bucket),
 		Prefix: aws.String(prefix),
 	}
 
-	result, err := svc.ListObjects(input)
+	// a single request returns at most 1000 keys, so every page is listed
+	err := svc.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, key := range page.Contents {
+			b := float64(*key.Size)
+			kb := b / 1024
+			mb := kb / 1024
+			current := filesystems.Listing{
+				Etag:         *key.ETag,
+				LastModified: *key.LastModified,
+				Key:          *key.Key,
+				Size:         mb,
+			}
+			listing = append(listing, current)
+		}
+		return true
+	})
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
@@ -111,19 +126,6 @@ func (s *S3) List(prefix string) ([]filesystems.Listing, error) {
 		return nil, err
 	}
 
-	for _, key := range result.Contents {
-		b := float64(*key.Size)
-		kb := b / 1024
-		mb := kb / 1024
-		current := filesystems.Listing{
-			Etag:         *key.ETag,
-			LastModified: *key.LastModified,
-			Key:          *key.Key,
-			Size:         mb,
-		}
-		listing = append(listing, current)
-	}
-
 	return listing, nil
 }
 
@@ -175,7 +177,7 @@ func (s *S3) Get(destination string, items ...string) error {
 
 	for _, item := range items {
 		err := func() error {
-			file, err := os.Create(fmt.Sprintf("%s/%s", destination, items))
+			file, err := os.Create(fmt.Sprintf("%s/%s", destination, path.Base(item)))
 			if err != nil {
 				return err
 			}
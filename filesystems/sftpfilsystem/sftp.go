@@ -97,6 +97,48 @@ func (s *SFTP) List(prefix string) ([]filesystems.Listing, error) {
 	return listing, nil
 }
 
+// Walk lists every file below prefix, in nested folders too, by its full path
+func (s *SFTP) Walk(prefix string) ([]filesystems.Listing, error) {
+	var listing []filesystems.Listing
+	client, err := s.getCredentials()
+	if err != nil {
+		return listing, err
+	}
+	defer client.Close()
+
+	if prefix == "" {
+		prefix = "."
+	}
+
+	walker := client.Walk(prefix)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return listing, err
+		}
+
+		x := walker.Stat()
+		if walker.Path() != prefix && strings.HasPrefix(x.Name(), ".") {
+			if x.IsDir() {
+				walker.SkipDir()
+			}
+			continue
+		}
+		if x.IsDir() {
+			continue
+		}
+
+		b := float64(x.Size())
+		kb := b / 1024
+		mb := kb / 1024
+		listing = append(listing, filesystems.Listing{
+			Key:          walker.Path(),
+			Size:         mb,
+			LastModified: x.ModTime(),
+		})
+	}
+	return listing, nil
+}
+
 func (s *SFTP) Delete(itemsToDelete []string) bool {
 	client, err := s.getCredentials()
 	if err != nil {
@@ -0,0 +1,202 @@
+package filesystems
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SyncOptions holds the settings for a sync between two file systems
+type SyncOptions struct {
+	FromPrefix  string
+	ToPrefix    string
+	Delete      bool
+	DryRun      bool
+	Concurrency int
+	TempDir     string
+}
+
+// SyncResult describes what a sync did (or would have done, on a dry run)
+type SyncResult struct {
+	Copied    []string
+	Deleted   []string
+	Unchanged []string
+	Errors    map[string]error
+}
+
+// Sync compares the listing of from with the listing of to, and copies every item that is missing or
+// differs (by size or modification time) from one to the other. When opts.Delete is set, items
+// that only exist on to are removed. With opts.DryRun nothing is changed, and the result holds the plan.
+func Sync(from, to FS, opts SyncOptions) (*SyncResult, error) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 4
+	}
+
+	sourceItems, err := listAll(from, opts.FromPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	targetItems, err := listAll(to, opts.ToPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	source := keyedListing(sourceItems, opts.FromPrefix)
+	target := keyedListing(targetItems, opts.ToPrefix)
+
+	result := &SyncResult{
+		Errors: make(map[string]error),
+	}
+
+	var toCopy []string
+	for _, key := range sortedKeys(source) {
+		existing, ok := target[key]
+		if ok && !itemChanged(source[key], existing) {
+			result.Unchanged = append(result.Unchanged, key)
+			continue
+		}
+		toCopy = append(toCopy, key)
+	}
+
+	var toDelete []string
+	if opts.Delete {
+		for _, key := range sortedKeys(target) {
+			if _, ok := source[key]; !ok {
+				toDelete = append(toDelete, key)
+			}
+		}
+	}
+
+	if opts.DryRun {
+		result.Copied = toCopy
+		result.Deleted = toDelete
+		return result, nil
+	}
+
+	tmpDir, err := os.MkdirTemp(opts.TempDir, "bendis-sync-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for key := range jobs {
+				err := copyItem(from, to, source[key].Key, key, opts.ToPrefix, fmt.Sprintf("%s/%d", tmpDir, worker))
+				mu.Lock()
+				if err != nil {
+					result.Errors[key] = err
+				} else {
+					result.Copied = append(result.Copied, key)
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	for _, key := range toCopy {
+		jobs <- key
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Strings(result.Copied)
+
+	for _, key := range toDelete {
+		if to.Delete([]string{target[key].Key}) {
+			result.Deleted = append(result.Deleted, key)
+		} else {
+			result.Errors[key] = fmt.Errorf("could not delete %s", target[key].Key)
+		}
+	}
+
+	return result, nil
+}
+
+// copyItem downloads a single item from one file system into a scratch folder, and uploads it to the other
+func copyItem(from, to FS, sourceKey, relativeKey, toPrefix, scratch string) error {
+	err := os.MkdirAll(scratch, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = from.Get(scratch, sourceKey)
+	if err != nil {
+		return err
+	}
+
+	localFile := fmt.Sprintf("%s/%s", scratch, path.Base(sourceKey))
+	defer func() {
+		_ = os.Remove(localFile)
+	}()
+
+	// items at the root have the folder ".", which the drivers would turn into the key ./name
+	dir := path.Dir(relativeKey)
+	if dir == "." {
+		dir = ""
+	}
+	return to.Put(localFile, path.Join(toPrefix, dir))
+}
+
+// listAll lists every file below prefix by its full key: with Walk on file systems that have it,
+// and with List on object stores, which list recursively and by full key already
+func listAll(fs FS, prefix string) ([]Listing, error) {
+	if w, ok := fs.(Walker); ok {
+		return w.Walk(prefix)
+	}
+	return fs.List(prefix)
+}
+
+// keyedListing maps a listing by key relative to prefix, skipping directories. Object stores match
+// prefixes as plain strings, so items that are not inside the prefix folder, such as uploads2/a.txt
+// for the prefix uploads, are left out.
+func keyedListing(items []Listing, prefix string) map[string]Listing {
+	keyed := make(map[string]Listing)
+	prefix = strings.Trim(prefix, "/")
+
+	for _, item := range items {
+		if item.IsDir {
+			continue
+		}
+		key := strings.Trim(item.Key, "/")
+		if prefix != "" {
+			if !strings.HasPrefix(key, prefix+"/") {
+				continue
+			}
+			key = key[len(prefix)+1:]
+		}
+		keyed[key] = item
+	}
+	return keyed
+}
+
+// itemChanged reports whether the source item differs from the copy on the target. Etags are not
+// compared, as every backend computes them differently.
+func itemChanged(source, target Listing) bool {
+	if source.Size != target.Size {
+		return true
+	}
+
+	return source.LastModified.After(target.LastModified)
+}
+
+func sortedKeys(m map[string]Listing) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
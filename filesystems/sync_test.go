@@ -0,0 +1,251 @@
+package filesystems
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryFS is a simple in-memory file system used to test Sync. Like an object store, it lists every
+// key that starts with the prefix, and like the drivers, it puts files at the key folder/name.
+type memoryFS struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	mod   map[string]time.Time
+}
+
+func newMemoryFS() *memoryFS {
+	return &memoryFS{files: make(map[string][]byte), mod: make(map[string]time.Time)}
+}
+
+func (m *memoryFS) Put(fileName, folder string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := fmt.Sprintf("%s/%s", folder, path.Base(fileName))
+	m.files[key] = data
+	m.mod[key] = time.Now()
+	return nil
+}
+
+func (m *memoryFS) Get(destination string, items ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, item := range items {
+		data, ok := m.files[item]
+		if !ok {
+			return fmt.Errorf("%s not found", item)
+		}
+		err := os.WriteFile(fmt.Sprintf("%s/%s", destination, path.Base(item)), data, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryFS) List(prefix string) ([]Listing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var listing []Listing
+	for key, data := range m.files {
+		if !strings.HasPrefix(key, strings.TrimPrefix(prefix, "/")) {
+			continue
+		}
+		listing = append(listing, Listing{Key: key, Size: float64(len(data)), LastModified: m.mod[key]})
+	}
+	return listing, nil
+}
+
+func (m *memoryFS) Delete(itemsToDelete []string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, item := range itemsToDelete {
+		delete(m.files, item)
+	}
+	return true
+}
+
+// folderFS lists like WebDAV and SFTP do: List returns the base names of the files directly in a
+// folder, and Walk the full paths of all files below it
+type folderFS struct {
+	*memoryFS
+}
+
+func (f folderFS) List(prefix string) ([]Listing, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var listing []Listing
+	for key, data := range f.files {
+		if path.Dir(key) == path.Clean(prefix) {
+			listing = append(listing, Listing{Key: path.Base(key), Size: float64(len(data)), LastModified: f.mod[key]})
+		}
+	}
+	return listing, nil
+}
+
+func (f folderFS) Walk(prefix string) ([]Listing, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var listing []Listing
+	for key, data := range f.files {
+		if prefix == "" || strings.HasPrefix(key, prefix+"/") {
+			listing = append(listing, Listing{Key: key, Size: float64(len(data)), LastModified: f.mod[key]})
+		}
+	}
+	return listing, nil
+}
+
+func TestSync(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	from := newMemoryFS()
+	from.files["docs/a.txt"] = []byte("alpha")
+	from.files["docs/b.txt"] = []byte("bravo")
+	from.files["c.txt"] = []byte("charlie")
+	for key := range from.files {
+		from.mod[key] = past
+	}
+
+	to := newMemoryFS()
+	to.files["docs/a.txt"] = []byte("alpha")
+	to.mod["docs/a.txt"] = time.Now()
+	to.files["docs/b.txt"] = []byte("old")
+	to.mod["docs/b.txt"] = time.Now()
+	to.files["stale.txt"] = []byte("stale")
+	to.mod["stale.txt"] = time.Now()
+
+	plan, err := Sync(from, to, SyncOptions{Delete: true, DryRun: true, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Copied) != 2 || len(plan.Deleted) != 1 || len(plan.Unchanged) != 1 {
+		t.Errorf("unexpected dry run plan: %+v", plan)
+	}
+
+	if _, ok := to.files["/c.txt"]; ok {
+		t.Error("dry run copied a file")
+	}
+
+	result, err := Sync(from, to, SyncOptions{Delete: true, Concurrency: 2, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Errors) > 0 {
+		t.Errorf("unexpected errors: %v", result.Errors)
+	}
+
+	if string(to.files["docs/b.txt"]) != "bravo" {
+		t.Error("changed file was not copied")
+	}
+
+	if string(to.files["/c.txt"]) != "charlie" {
+		t.Errorf("missing file was not copied to /c.txt: %v", to.files)
+	}
+
+	if _, ok := to.files["stale.txt"]; ok {
+		t.Error("stale file was not deleted")
+	}
+
+	// the copies are found again, rather than copied a second time
+	again, err := Sync(from, to, SyncOptions{Delete: true, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(again.Copied) != 0 || len(again.Deleted) != 0 {
+		t.Errorf("expected nothing to change on a second sync, got %+v", again)
+	}
+}
+
+func TestSync_Prefix(t *testing.T) {
+	from := newMemoryFS()
+	from.files["uploads/a.txt"] = []byte("alpha")
+	from.files["uploads/nested/b.txt"] = []byte("bravo")
+	from.files["uploads2/c.txt"] = []byte("charlie")
+
+	to := newMemoryFS()
+
+	result, err := Sync(from, to, SyncOptions{FromPrefix: "uploads", ToPrefix: "backup", TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Errors) > 0 {
+		t.Errorf("unexpected errors: %v", result.Errors)
+	}
+
+	if string(to.files["backup/a.txt"]) != "alpha" {
+		t.Error("file was not copied into the target prefix")
+	}
+
+	if string(to.files["backup/nested/b.txt"]) != "bravo" {
+		t.Error("nested file was not copied")
+	}
+
+	if len(to.files) != 2 {
+		t.Errorf("files outside the prefix were copied: %v", to.files)
+	}
+}
+
+func TestSync_DeleteInPrefix(t *testing.T) {
+	from := newMemoryFS()
+	from.files["uploads/a.txt"] = []byte("alpha")
+
+	to := folderFS{newMemoryFS()}
+	to.files["/uploads/a.txt"] = []byte("alpha")
+	to.files["/uploads/nested/stale.txt"] = []byte("stale")
+	to.files["stale.txt"] = []byte("outside the prefix")
+	for key := range to.files {
+		to.mod[key] = time.Now()
+	}
+
+	result, err := Sync(from, to, SyncOptions{FromPrefix: "uploads", ToPrefix: "/uploads", Delete: true, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Deleted) != 1 || result.Deleted[0] != "nested/stale.txt" {
+		t.Errorf("unexpected deletions: %v", result.Deleted)
+	}
+
+	if _, ok := to.files["/uploads/nested/stale.txt"]; ok {
+		t.Error("nested stale file was not deleted")
+	}
+
+	if _, ok := to.files["stale.txt"]; !ok {
+		t.Error("file outside the prefix was deleted")
+	}
+}
+
+func TestItemChanged(t *testing.T) {
+	now := time.Now()
+
+	var tests = []struct {
+		name    string
+		source  Listing
+		target  Listing
+		changed bool
+	}{
+		{"same", Listing{Size: 1, LastModified: now}, Listing{Size: 1, LastModified: now}, false},
+		{"other etag", Listing{Size: 1, Etag: "a", LastModified: now}, Listing{Size: 1, Etag: "b", LastModified: now}, false},
+		{"other size", Listing{Size: 1, LastModified: now}, Listing{Size: 2, LastModified: now}, true},
+		{"newer source", Listing{Size: 1, LastModified: now}, Listing{Size: 1, LastModified: now.Add(-time.Minute)}, true},
+		{"older source", Listing{Size: 1, LastModified: now.Add(-time.Minute)}, Listing{Size: 1, LastModified: now}, false},
+	}
+
+	for _, e := range tests {
+		if itemChanged(e.source, e.target) != e.changed {
+			t.Errorf("%s: expected changed to be %t", e.name, e.changed)
+		}
+	}
+}
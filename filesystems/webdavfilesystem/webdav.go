@@ -65,6 +65,46 @@ func (w *WebDAV) List(prefix string) ([]filesystems.Listing, error) {
 	return listing, nil
 }
 
+// Walk lists every file below prefix, in nested folders too, by its full path
+func (w *WebDAV) Walk(prefix string) ([]filesystems.Listing, error) {
+	return walk(w.getCredentials(), prefix)
+}
+
+func walk(client *gowebdav.Client, folder string) ([]filesystems.Listing, error) {
+	var listing []filesystems.Listing
+
+	files, err := client.ReadDir(folder)
+	if err != nil {
+		return listing, err
+	}
+
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+
+		key := path.Join(folder, file.Name())
+		if file.IsDir() {
+			nested, err := walk(client, key)
+			if err != nil {
+				return listing, err
+			}
+			listing = append(listing, nested...)
+			continue
+		}
+
+		b := float64(file.Size())
+		kb := b / 1024
+		mb := kb / 1024
+		listing = append(listing, filesystems.Listing{
+			LastModified: file.ModTime(),
+			Key:          key,
+			Size:         mb,
+		})
+	}
+	return listing, nil
+}
+
 func (w *WebDAV) Delete(itemsToDelete []string) bool {
 	client := w.getCredentials()
 
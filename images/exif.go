@@ -0,0 +1,122 @@
+package images
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+// readOrientation returns the EXIF orientation (1-8) of a jpeg, or 1 if there is none
+func readOrientation(r io.Reader) int {
+	var marker [2]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil || marker != [2]byte{0xff, 0xd8} {
+		return 1
+	}
+
+	// walk the jpeg segments until we find APP1 (exif) or the start of the image data
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil || header[0] != 0xff {
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(header[2:])) - 2
+		if length < 0 {
+			return 1
+		}
+
+		if header[1] == 0xda {
+			return 1
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return 1
+		}
+
+		if header[1] == 0xe1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return orientationFromTIFF(segment[6:])
+		}
+	}
+}
+
+func orientationFromTIFF(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		// tag 0x0112 is the orientation, stored as a short
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+	}
+
+	return 1
+}
+
+// applyOrientation rotates and flips img so that it displays upright without the EXIF tag
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// orientations 5-8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,281 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels is the largest width x height Open decodes. Decoding takes about four bytes per pixel,
+// so a small file claiming to be a huge image could otherwise use up all memory.
+var MaxPixels = 50000000
+
+// ErrTooLarge is returned by Open for images with more than MaxPixels pixels
+var ErrTooLarge = errors.New("image is too large")
+
+// Resize modes for a Variant
+const (
+	// ModeResize scales the image to Width x Height. If one of them is zero, the aspect ratio is kept.
+	ModeResize = "resize"
+	// ModeFit scales the image down so that it fits within Width x Height, keeping the aspect ratio
+	ModeFit = "fit"
+	// ModeFill scales and crops the image from the centre so that it is exactly Width x Height
+	ModeFill = "fill"
+	// ModeCrop cuts a Width x Height region from the centre of the image without scaling
+	ModeCrop = "crop"
+)
+
+// Variant describes one derived version of an uploaded image, e.g. a thumbnail
+type Variant struct {
+	Name    string
+	Mode    string
+	Width   int
+	Height  int
+	Format  string // jpeg, png, gif or webp; empty keeps the original format, except webp, which becomes jpeg
+	Quality int    // jpeg quality, 1-100; defaults to 85. webp is written lossless, so a photo is smaller as jpeg.
+}
+
+// Thumbnail is a convenience for a square, cropped variant
+func Thumbnail(name string, size int) Variant {
+	return Variant{Name: name, Mode: ModeFill, Width: size, Height: size}
+}
+
+// Process reads the image at src, applies EXIF orientation, and writes every variant into dir as
+// <name>-<variant>.<ext>. Since images are decoded and re-encoded, all metadata (EXIF, GPS, etc.)
+// is stripped from the variants. It returns the paths of the written files, keyed by variant name.
+func Process(src, dir string, variants ...Variant) (map[string]string, error) {
+	for _, v := range variants {
+		if !canEncode(outputFormat(v.Format, "")) {
+			return nil, fmt.Errorf("%s: cannot encode images as %s; use jpeg, png, gif or webp", v.Name, v.Format)
+		}
+	}
+
+	img, format, err := Open(src)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(path.Base(src), path.Ext(src))
+	files := make(map[string]string)
+
+	for _, v := range variants {
+		out, err := Transform(img, v)
+		if err != nil {
+			removeAll(files)
+			return nil, fmt.Errorf("%s: %w", v.Name, err)
+		}
+
+		outFormat := outputFormat(v.Format, format)
+		fileName := fmt.Sprintf("%s/%s-%s.%s", dir, base, v.Name, extension(outFormat))
+
+		err = Save(out, fileName, outFormat, v.Quality)
+		if err != nil {
+			removeAll(files)
+			return nil, fmt.Errorf("%s: %w", v.Name, err)
+		}
+		files[v.Name] = fileName
+	}
+
+	return files, nil
+}
+
+// Open decodes the image at src and rotates it according to its EXIF orientation tag, if any. Images
+// with more than MaxPixels pixels are refused with ErrTooLarge before they are decoded.
+func Open(src string) (image.Image, string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, "", err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, "", fmt.Errorf("%w: %dx%d", ErrTooLarge, config.Width, config.Height)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, "", err
+	}
+
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if format == "jpeg" {
+		if _, err := f.Seek(0, 0); err == nil {
+			img = applyOrientation(img, readOrientation(f))
+		}
+	}
+
+	return img, format, nil
+}
+
+// Transform returns a new image for the given variant
+func Transform(img image.Image, v Variant) (image.Image, error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	if v.Width < 0 || v.Height < 0 || (v.Width == 0 && v.Height == 0) {
+		return nil, errors.New("a variant needs a width or a height")
+	}
+
+	switch v.Mode {
+	case ModeResize, "":
+		tw, th := v.Width, v.Height
+		if tw == 0 {
+			tw = w * th / h
+		}
+		if th == 0 {
+			th = h * tw / w
+		}
+		return scale(img, bounds, tw, th), nil
+
+	case ModeFit:
+		tw, th := fitWithin(w, h, v.Width, v.Height)
+		if tw >= w && th >= h {
+			return scale(img, bounds, w, h), nil
+		}
+		return scale(img, bounds, tw, th), nil
+
+	case ModeFill:
+		if v.Width == 0 || v.Height == 0 {
+			return nil, errors.New("fill needs both a width and a height")
+		}
+		// crop the largest centred region with the target aspect ratio, then scale it
+		cw, ch := w, w*v.Height/v.Width
+		if ch > h {
+			cw, ch = h*v.Width/v.Height, h
+		}
+		return scale(img, centred(bounds, cw, ch), v.Width, v.Height), nil
+
+	case ModeCrop:
+		cw, ch := smaller(v.Width, w), smaller(v.Height, h)
+		if cw == 0 {
+			cw = w
+		}
+		if ch == 0 {
+			ch = h
+		}
+		return scale(img, centred(bounds, cw, ch), cw, ch), nil
+	}
+
+	return nil, fmt.Errorf("unknown mode %q", v.Mode)
+}
+
+// Save encodes img into fileName as jpeg, png, gif or webp. webp images are written lossless, and so
+// are larger than jpeg images of photos.
+func Save(img image.Image, fileName, format string, quality int) error {
+	if !canEncode(format) {
+		return fmt.Errorf("cannot encode images as %s", format)
+	}
+
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch format {
+	case "jpeg":
+		if quality < 1 || quality > 100 {
+			quality = 85
+		}
+		return jpeg.Encode(f, img, &jpeg.Options{Quality: quality})
+	case "png":
+		return png.Encode(f, img)
+	case "gif":
+		return gif.Encode(f, img, nil)
+	case "webp":
+		return encodeWebP(f, img)
+	}
+
+	return fmt.Errorf("cannot encode images as %s", format)
+}
+
+func scale(img image.Image, src image.Rectangle, w, h int) image.Image {
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+	return dst
+}
+
+func fitWithin(w, h, maxW, maxH int) (int, int) {
+	if maxW == 0 {
+		return w * maxH / h, maxH
+	}
+	if maxH == 0 {
+		return maxW, h * maxW / w
+	}
+	if w*maxH > h*maxW {
+		return maxW, h * maxW / w
+	}
+	return w * maxH / h, maxH
+}
+
+func centred(bounds image.Rectangle, w, h int) image.Rectangle {
+	x := bounds.Min.X + (bounds.Dx()-w)/2
+	y := bounds.Min.Y + (bounds.Dy()-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+func outputFormat(requested, original string) string {
+	if requested != "" {
+		requested = strings.ToLower(strings.TrimPrefix(requested, "."))
+		if requested == "jpg" {
+			return "jpeg"
+		}
+		return requested
+	}
+
+	switch original {
+	case "png", "gif":
+		return original
+	}
+	return "jpeg"
+}
+
+func canEncode(format string) bool {
+	switch format {
+	case "jpeg", "png", "gif", "webp":
+		return true
+	}
+	return false
+}
+
+func extension(format string) string {
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
+
+func removeAll(files map[string]string) {
+	for _, f := range files {
+		_ = os.Remove(f)
+	}
+}
+
+func smaller(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,122 @@
+package images
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+)
+
+var transformTests = []struct {
+	name    string
+	variant Variant
+	width   int
+	height  int
+}{
+	{"resize", Variant{Mode: ModeResize, Width: 100, Height: 50}, 100, 50},
+	{"resize_keep_ratio", Variant{Mode: ModeResize, Width: 200}, 200, 100},
+	{"fit", Variant{Mode: ModeFit, Width: 100, Height: 100}, 100, 50},
+	{"fit_no_upscale", Variant{Mode: ModeFit, Width: 1000, Height: 1000}, 400, 200},
+	{"fill", Variant{Mode: ModeFill, Width: 64, Height: 64}, 64, 64},
+	{"crop", Variant{Mode: ModeCrop, Width: 50, Height: 60}, 50, 60},
+}
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 200; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+func TestTransform(t *testing.T) {
+	img := testImage()
+
+	for _, e := range transformTests {
+		out, err := Transform(img, e.variant)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+		if out.Bounds().Dx() != e.width || out.Bounds().Dy() != e.height {
+			t.Errorf("%s: expected %dx%d, got %dx%d", e.name, e.width, e.height, out.Bounds().Dx(), out.Bounds().Dy())
+		}
+	}
+
+	if _, err := Transform(img, Variant{Mode: ModeFill, Width: 10}); err == nil {
+		t.Error("expected an error for fill without a height")
+	}
+}
+
+func TestProcess(t *testing.T) {
+	dir := t.TempDir()
+	src := dir + "/photo.png"
+
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = png.Encode(f, testImage())
+	_ = f.Close()
+
+	files, err := Process(src, dir, Thumbnail("thumb", 32), Variant{Name: "large", Mode: ModeFit, Width: 200, Format: "jpg"}, Variant{Name: "modern", Width: 100, Format: "webp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files["thumb"] != dir+"/photo-thumb.png" || files["large"] != dir+"/photo-large.jpg" || files["modern"] != dir+"/photo-modern.webp" {
+		t.Errorf("unexpected variant files: %v", files)
+	}
+
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.White)
+
+	// orientation 6 means the camera was rotated 90 degrees clockwise
+	out := applyOrientation(img, 6)
+	if out.Bounds().Dx() != 1 || out.Bounds().Dy() != 2 {
+		t.Fatalf("expected 1x2, got %v", out.Bounds())
+	}
+
+	r, _, _, _ := out.At(0, 0).RGBA()
+	if r == 0 {
+		t.Error("top left pixel was not rotated into place")
+	}
+}
+
+func TestProcess_Refused(t *testing.T) {
+	dir := t.TempDir()
+	src := dir + "/photo.png"
+
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = png.Encode(f, testImage())
+	_ = f.Close()
+
+	if _, err := Process(src, dir, Variant{Name: "modern", Width: 100, Format: "avif"}); err == nil {
+		t.Error("expected an error for avif output")
+	}
+
+	maxPixels := MaxPixels
+	MaxPixels = 400*200 - 1
+	defer func() {
+		MaxPixels = maxPixels
+	}()
+
+	if _, err := Process(src, dir, Thumbnail("thumb", 32)); !errors.Is(err, ErrTooLarge) {
+		t.Error("expected ErrTooLarge, got", err)
+	}
+}
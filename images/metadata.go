@@ -0,0 +1,139 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+var errInvalidImage = errors.New("image is invalid or truncated")
+
+// StripMetadata removes EXIF, XMP and text metadata, which may hold where a photo was taken or the
+// serial number of the camera, from the jpeg, png or webp image at fileName, in place. The image data
+// itself is copied as it is, except for jpegs that EXIF says to rotate: those are rotated and
+// encoded again, as they would display sideways without the tag. Other formats are left alone.
+func StripMetadata(fileName string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	var stripped []byte
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		if readOrientation(bytes.NewReader(data)) > 1 {
+			img, _, err := Open(fileName)
+			if err != nil {
+				return err
+			}
+			return Save(img, fileName, "jpeg", 95)
+		}
+		stripped, err = stripJPEG(data)
+	case bytes.HasPrefix(data, pngSignature):
+		stripped, err = stripPNG(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		stripped, err = stripWebP(data)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fileName, stripped, 0644)
+}
+
+// stripJPEG drops the APP1 (EXIF and XMP), APP13 (IPTC) and comment segments. Everything from the
+// start of the image data on is copied as it is.
+func stripJPEG(data []byte) ([]byte, error) {
+	out := append([]byte(nil), data[:2]...)
+
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xff {
+			return nil, errInvalidImage
+		}
+
+		marker := data[i+1]
+		if marker == 0xff {
+			// a fill byte
+			i++
+			continue
+		}
+		if marker == 0xda {
+			return append(out, data[i:]...), nil
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			return nil, errInvalidImage
+		}
+
+		switch marker {
+		case 0xe1, 0xed, 0xfe:
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+}
+
+// stripPNG drops the eXIf, text and tIME chunks
+func stripPNG(data []byte) ([]byte, error) {
+	out := append([]byte(nil), pngSignature...)
+
+	for i := len(pngSignature); i < len(data); {
+		if i+12 > len(data) {
+			return nil, errInvalidImage
+		}
+
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return nil, errInvalidImage
+		}
+
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	return out, nil
+}
+
+// stripWebP drops the EXIF and XMP chunks, and clears their flags in the VP8X chunk
+func stripWebP(data []byte) ([]byte, error) {
+	out := append([]byte(nil), data[:12]...)
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errInvalidImage
+		}
+
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size&1
+		if end > len(data) || end < i {
+			return nil, errInvalidImage
+		}
+
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
@@ -0,0 +1,191 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// exifSegment returns a jpeg APP1 segment with the given orientation, followed by a secret
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = append(tiff, 1, 0)
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry, 0x0112)
+	binary.LittleEndian.PutUint16(entry[2:], 3)
+	binary.LittleEndian.PutUint32(entry[4:], 1)
+	binary.LittleEndian.PutUint16(entry[8:], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, "secret location"...)
+
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+6+len(tiff)))
+	segment = append(segment, "Exif\x00\x00"...)
+	return append(segment, tiff...)
+}
+
+func testJPEG(t *testing.T, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	comment := append([]byte{0xff, 0xfe, 0, 16}, "secret comment"...)
+
+	out := append([]byte(nil), data[:2]...)
+	out = append(out, exifSegment(orientation)...)
+	out = append(out, comment...)
+	return append(out, data[2:]...)
+}
+
+func testPNG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	text := []byte("tEXtComment\x00secret location")
+	chunk := make([]byte, 4+len(text)+4)
+	binary.BigEndian.PutUint32(chunk, uint32(len(text)-4))
+	copy(chunk[4:], text)
+	binary.BigEndian.PutUint32(chunk[4+len(text):], crc32.ChecksumIEEE(text))
+
+	// after the IHDR chunk
+	out := append([]byte(nil), data[:33]...)
+	out = append(out, chunk...)
+	return append(out, data[33:]...)
+}
+
+func testWebP(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := encodeWebP(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+
+	// an extended file, with the EXIF flag set and an EXIF chunk of odd size
+	vp8x := []byte("VP8X\x0a\x00\x00\x00\x08\x00\x00\x00")
+	vp8x = append(vp8x, 399&0xff, 399>>8, 0, 199, 0, 0)
+	exif := []byte("EXIF\x0f\x00\x00\x00secret location\x00")
+
+	out := []byte("RIFF\x00\x00\x00\x00WEBP")
+	out = append(out, vp8x...)
+	out = append(out, buf.Bytes()[12:]...)
+	out = append(out, exif...)
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
+
+func TestStripMetadata(t *testing.T) {
+	var tests = []struct {
+		name   string
+		data   []byte
+		decode func([]byte) (int, int, error)
+		width  int
+		height int
+	}{
+		{"jpeg", testJPEG(t, 1), decodeJPEG, 400, 200},
+		{"rotated jpeg", testJPEG(t, 6), decodeJPEG, 200, 400},
+		{"png", testPNG(t), decodePNG, 400, 200},
+		{"webp", testWebP(t), decodeWebP, 400, 200},
+	}
+
+	for _, e := range tests {
+		fileName := t.TempDir() + "/photo"
+		if err := os.WriteFile(fileName, e.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := StripMetadata(fileName); err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Contains(data, []byte("secret")) {
+			t.Errorf("%s: the metadata was kept", e.name)
+		}
+
+		width, height, err := e.decode(data)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+		if width != e.width || height != e.height {
+			t.Errorf("%s: expected %dx%d, got %dx%d", e.name, e.width, e.height, width, height)
+		}
+	}
+}
+
+func TestStripMetadata_WebPHeader(t *testing.T) {
+	stripped, err := stripWebP(testWebP(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if int(binary.LittleEndian.Uint32(stripped[4:])) != len(stripped)-8 {
+		t.Error("the RIFF size does not match the file")
+	}
+	if stripped[20]&0x08 != 0 {
+		t.Error("the EXIF flag is still set")
+	}
+}
+
+func TestStripMetadata_Invalid(t *testing.T) {
+	var tests = []struct {
+		name   string
+		data   []byte
+		failed bool
+	}{
+		{"truncated png", testPNG(t)[:40], true},
+		{"truncated jpeg", testJPEG(t, 1)[:30], true},
+		{"other format", []byte("GIF89a"), false},
+	}
+
+	for _, e := range tests {
+		fileName := t.TempDir() + "/photo"
+		if err := os.WriteFile(fileName, e.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := StripMetadata(fileName); (err != nil) != e.failed {
+			t.Errorf("%s: expected failed to be %t, got %v", e.name, e.failed, err)
+		}
+	}
+}
+
+func decodeJPEG(data []byte) (int, int, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return img.Bounds().Dx(), img.Bounds().Dy(), nil
+}
+
+func decodePNG(data []byte) (int, int, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return img.Bounds().Dx(), img.Bounds().Dy(), nil
+}
+
+func decodeWebP(data []byte) (int, int, error) {
+	img, err := webp.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return img.Bounds().Dx(), img.Bounds().Dy(), nil
+}
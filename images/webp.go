@@ -0,0 +1,455 @@
+package images
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+
+	"golang.org/x/image/draw"
+)
+
+// x/image only decodes webp, so webp files are written by the encoder below. It writes lossless
+// (VP8L) images: the pixels go through the subtract green and predictor transforms, and are then
+// Huffman coded one by one, without backward references or a colour cache. The files are larger
+// than those of libwebp, but any webp decoder reads them.
+
+// webpMaxSize is the largest width and height a webp image can have
+const webpMaxSize = 1 << 14
+
+// predictorBits sets the size of the blocks a predictor is chosen for, 32x32 pixels
+const predictorBits = 5
+
+// predictorModes are the predictors tried for each block: the pixel to the left, the one above,
+// and the average of the two
+var predictorModes = []uint32{1, 2, 7}
+
+// codeLengthOrder is the order the lengths of the code length code are written in
+var codeLengthOrder = []int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// encodeWebP writes img to w as a lossless webp
+func encodeWebP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > webpMaxSize || height > webpMaxSize {
+		return fmt.Errorf("cannot encode a %dx%d image as webp", width, height)
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+
+	alpha := false
+	argb := make([]uint32, width*height)
+	for i := range argb {
+		p := nrgba.Pix[4*i : 4*i+4]
+		argb[i] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		if p[3] != 0xff {
+			alpha = true
+		}
+	}
+
+	var bw bitWriter
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	bw.write(boolBit(alpha), 1)
+	bw.write(0, 3)
+
+	// the transforms are listed in the order they are applied; decoders undo them the other way round
+	bw.write(1, 1)
+	bw.write(2, 2)
+	subtractGreen(argb)
+
+	bw.write(1, 1)
+	bw.write(0, 2)
+	bw.write(predictorBits-2, 3)
+	modes, residuals := predict(argb, width, height)
+	writePixels(&bw, modes, false)
+
+	bw.write(0, 1)
+	writePixels(&bw, residuals, true)
+
+	data := bw.flush()
+	padding := len(data) & 1
+
+	var header [20]byte
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+len(data)+padding))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if padding == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// subtractGreen subtracts the green value of every pixel from its red and blue values
+func subtractGreen(argb []uint32) {
+	for i, p := range argb {
+		green := p >> 8 & 0xff
+		red := (p>>16 - green) & 0xff
+		blue := (p - green) & 0xff
+		argb[i] = p&0xff00ff00 | red<<16 | blue
+	}
+}
+
+// predict chooses the predictor that fits each block best, and returns the chosen modes as an
+// image with one pixel per block and the mode in green, along with the residuals: every pixel
+// minus its prediction
+func predict(argb []uint32, width, height int) ([]uint32, []uint32) {
+	tiles := (width + 1<<predictorBits - 1) >> predictorBits
+	rows := (height + 1<<predictorBits - 1) >> predictorBits
+
+	modes := make([]uint32, tiles*rows)
+	residuals := make([]uint32, len(argb))
+
+	for ty := 0; ty < rows; ty++ {
+		for tx := 0; tx < tiles; tx++ {
+			x0, y0 := tx<<predictorBits, ty<<predictorBits
+			x1, y1 := smaller(x0+1<<predictorBits, width), smaller(y0+1<<predictorBits, height)
+
+			best, bestCost := predictorModes[0], -1
+			for _, mode := range predictorModes {
+				cost := 0
+				for y := y0; y < y1; y++ {
+					for x := x0; x < x1; x++ {
+						cost += residualCost(subPixels(argb[y*width+x], prediction(argb, width, x, y, mode)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+
+			modes[ty*tiles+tx] = 0xff000000 | best<<8
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					residuals[y*width+x] = subPixels(argb[y*width+x], prediction(argb, width, x, y, best))
+				}
+			}
+		}
+	}
+
+	return modes, residuals
+}
+
+// prediction returns what mode predicts for the pixel at x, y. The first pixel is predicted as
+// opaque black, the rest of the top row from the left, and the left column from above, whatever
+// the mode.
+func prediction(argb []uint32, width, x, y int, mode uint32) uint32 {
+	i := y*width + x
+	switch {
+	case x == 0 && y == 0:
+		return 0xff000000
+	case y == 0:
+		return argb[i-1]
+	case x == 0:
+		return argb[i-width]
+	}
+
+	left, top := argb[i-1], argb[i-width]
+	switch mode {
+	case 1:
+		return left
+	case 2:
+		return top
+	}
+	// the average of each channel, rounded down
+	return (left^top)&0xfefefefe>>1 + left&top
+}
+
+// subPixels subtracts b from a channel by channel, wrapping around
+func subPixels(a, b uint32) uint32 {
+	var out uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		out |= uint32(uint8(a>>shift)-uint8(b>>shift)) << shift
+	}
+	return out
+}
+
+// residualCost estimates how many bits a residual takes: residuals near zero are cheap
+func residualCost(residual uint32) int {
+	cost := 0
+	for shift := uint(0); shift < 32; shift += 8 {
+		v := int(uint8(residual >> shift))
+		if v > 128 {
+			v = 256 - v
+		}
+		cost += v
+	}
+	return cost
+}
+
+// writePixels writes pixels as literals, after the prefix codes for green, red, blue, alpha and
+// distances. The main image also says it uses a single set of codes.
+func writePixels(bw *bitWriter, pixels []uint32, main bool) {
+	// no colour cache
+	bw.write(0, 1)
+	if main {
+		bw.write(0, 1)
+	}
+
+	// green includes the codes for backward reference lengths, and there are 40 distance codes;
+	// neither is used, but their codes are written all the same
+	counts := [5][]int{make([]int, 256+24), make([]int, 256), make([]int, 256), make([]int, 256), make([]int, 40)}
+	for _, p := range pixels {
+		counts[0][p>>8&0xff]++
+		counts[1][p>>16&0xff]++
+		counts[2][p&0xff]++
+		counts[3][p>>24]++
+	}
+
+	var codes [5]prefixCode
+	for i := range counts {
+		codes[i] = writePrefixCode(bw, counts[i])
+	}
+
+	for _, p := range pixels {
+		codes[0].write(bw, p>>8&0xff)
+		codes[1].write(bw, p>>16&0xff)
+		codes[2].write(bw, p&0xff)
+		codes[3].write(bw, p>>24)
+	}
+}
+
+// prefixCode holds the Huffman code of every symbol, with its bits reversed, as codes are read from
+// their most significant bit
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint32
+}
+
+func (c prefixCode) write(bw *bitWriter, symbol uint32) {
+	bw.write(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// writePrefixCode writes a code for symbols with the given counts, and returns it. One or two
+// symbols below 256 are written as a simple code.
+func writePrefixCode(bw *bitWriter, counts []int) prefixCode {
+	var used []uint32
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, uint32(symbol))
+		}
+	}
+
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		if len(used) == 0 {
+			used = []uint32{0}
+		}
+
+		bw.write(1, 1)
+		bw.write(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			bw.write(0, 1)
+			bw.write(used[0], 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(used[0], 8)
+		}
+
+		c := prefixCode{lengths: make([]uint8, len(counts)), codes: make([]uint32, len(counts))}
+		if len(used) == 2 {
+			bw.write(used[1], 8)
+			c.lengths[used[0]], c.lengths[used[1]] = 1, 1
+			c.codes[used[1]] = 1
+		}
+		return c
+	}
+
+	lengths := huffmanLengths(counts, 15)
+
+	// the code lengths are written with a code of their own
+	lengthCounts := make([]int, 19)
+	for _, l := range lengths {
+		lengthCounts[l]++
+	}
+	lengthCode := newPrefixCode(huffmanLengths(lengthCounts, 7))
+
+	n := 4
+	for i, symbol := range codeLengthOrder {
+		if lengthCode.lengths[symbol] > 0 && i+1 > n {
+			n = i + 1
+		}
+	}
+
+	bw.write(0, 1)
+	bw.write(uint32(n-4), 4)
+	for _, symbol := range codeLengthOrder[:n] {
+		bw.write(uint32(lengthCode.lengths[symbol]), 3)
+	}
+
+	// a length is written for every symbol
+	bw.write(0, 1)
+	for _, l := range lengths {
+		lengthCode.write(bw, uint32(l))
+	}
+
+	return newPrefixCode(lengths)
+}
+
+// newPrefixCode returns the canonical code for the given code lengths: shorter codes come first,
+// and codes of the same length follow the order of the symbols
+func newPrefixCode(lengths []uint8) prefixCode {
+	var count [16]uint32
+	for _, l := range lengths {
+		count[l]++
+	}
+	count[0] = 0
+
+	var next [16]uint32
+	code := uint32(0)
+	for l := 1; l < len(next); l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+
+	c := prefixCode{lengths: lengths, codes: make([]uint32, len(lengths))}
+	for symbol, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		code := next[l]
+		next[l]++
+
+		var reversed uint32
+		for i := uint8(0); i < l; i++ {
+			reversed = reversed<<1 | code>>i&1
+		}
+		c.codes[symbol] = reversed
+	}
+	return c
+}
+
+// huffmanLengths returns the Huffman code lengths for symbols with the given counts, none longer
+// than limit. Rare symbols are counted as more common until the code fits.
+func huffmanLengths(counts []int, limit int) []uint8 {
+	lengths := make([]uint8, len(counts))
+
+	var symbols []int
+	for symbol, count := range counts {
+		if count > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	switch len(symbols) {
+	case 0:
+		return lengths
+	case 1:
+		// a code needs two symbols; the second one is never written
+		other := 0
+		if symbols[0] == 0 {
+			other = 1
+		}
+		lengths[symbols[0]], lengths[other] = 1, 1
+		return lengths
+	}
+
+	for least := 1; ; least *= 2 {
+		if buildLengths(counts, symbols, least, lengths) <= limit {
+			return lengths
+		}
+	}
+}
+
+// buildLengths sets the Huffman code lengths of symbols, counting each at least least times, and
+// returns the longest
+func buildLengths(counts []int, symbols []int, least int, lengths []uint8) int {
+	type node struct {
+		count  int
+		parent int
+	}
+
+	nodes := make([]node, len(symbols), 2*len(symbols)-1)
+	for i, symbol := range symbols {
+		count := counts[symbol]
+		if count < least {
+			count = least
+		}
+		nodes[i] = node{count: count, parent: -1}
+	}
+
+	// the leaves in order of count, and the joined nodes, which are made in order of count too, are
+	// two queues; the two smallest nodes are always at their fronts
+	leaves := make([]int, len(symbols))
+	for i := range leaves {
+		leaves[i] = i
+	}
+	sort.SliceStable(leaves, func(a, b int) bool {
+		return nodes[leaves[a]].count < nodes[leaves[b]].count
+	})
+
+	leaf, joined := 0, len(symbols)
+	smallest := func() int {
+		if leaf < len(leaves) && (joined == len(nodes) || nodes[leaves[leaf]].count <= nodes[joined].count) {
+			leaf++
+			return leaves[leaf-1]
+		}
+		joined++
+		return joined - 1
+	}
+
+	for len(nodes) < cap(nodes) {
+		a, b := smallest(), smallest()
+		nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, parent: -1})
+		nodes[a].parent, nodes[b].parent = len(nodes)-1, len(nodes)-1
+	}
+
+	// parents come after their children, so depths can be filled in from the root down
+	depth := make([]int, len(nodes))
+	for i := len(nodes) - 2; i >= 0; i-- {
+		depth[i] = depth[nodes[i].parent] + 1
+	}
+
+	longest := 0
+	for i, symbol := range symbols {
+		lengths[symbol] = uint8(depth[i])
+		if depth[i] > longest {
+			longest = depth[i]
+		}
+	}
+	return longest
+}
+
+// bitWriter packs bits into bytes, least significant bit first
+type bitWriter struct {
+	buf   []byte
+	bits  uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	w.bits |= uint64(v) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits >>= 8
+		w.nbits -= 8
+	}
+}
+
+// flush writes out the last, partial byte, and returns everything written
+func (w *bitWriter) flush() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits, w.nbits = 0, 0
+	}
+	return w.buf
+}
+
+func boolBit(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
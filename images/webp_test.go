@@ -0,0 +1,99 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebP(t *testing.T) {
+	noise := image.NewNRGBA(image.Rect(0, 0, 33, 17))
+	random := rand.New(rand.NewSource(1))
+	random.Read(noise.Pix)
+
+	solid := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	for i := range solid.Pix {
+		solid.Pix[i] = 0x80
+	}
+
+	twoColours := image.NewGray(image.Rect(0, 0, 70, 3))
+	for x := 0; x < 70; x += 2 {
+		twoColours.SetGray(x, 1, color.Gray{Y: 255})
+	}
+
+	var tests = []struct {
+		name string
+		img  image.Image
+	}{
+		{"gradient", testImage()},
+		{"single pixel", image.NewRGBA(image.Rect(0, 0, 1, 1))},
+		{"noise with alpha", noise},
+		{"solid", solid},
+		{"two colours", twoColours},
+		{"offset bounds", testImage().(*image.RGBA).SubImage(image.Rect(10, 20, 110, 70))},
+	}
+
+	for _, e := range tests {
+		var buf bytes.Buffer
+		if err := encodeWebP(&buf, e.img); err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+
+		decoded, err := webp.Decode(&buf)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+
+		b := e.img.Bounds()
+		if decoded.Bounds().Dx() != b.Dx() || decoded.Bounds().Dy() != b.Dy() {
+			t.Errorf("%s: expected %dx%d, got %v", e.name, b.Dx(), b.Dy(), decoded.Bounds())
+			continue
+		}
+
+	pixels:
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				expected := color.NRGBAModel.Convert(e.img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+				got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+				// fully transparent pixels have no colour to keep
+				if expected != got && !(expected.A == 0 && got.A == 0) {
+					t.Errorf("%s: pixel %d,%d: expected %v, got %v", e.name, x, y, expected, got)
+					break pixels
+				}
+			}
+		}
+	}
+
+	if err := encodeWebP(&bytes.Buffer{}, image.NewRGBA(image.Rect(0, 0, webpMaxSize+1, 1))); err == nil {
+		t.Error("expected an error for an image too wide for webp")
+	}
+}
+
+func TestHuffmanLengths(t *testing.T) {
+	// counts that would give a code longer than 15 bits without a limit
+	counts := make([]int, 30)
+	for i := range counts {
+		counts[i] = 1 << uint(i)
+	}
+
+	lengths := huffmanLengths(counts, 15)
+
+	kraft := 0.0
+	for _, l := range lengths {
+		if l > 15 {
+			t.Errorf("expected no code longer than 15 bits, got %d", l)
+		}
+		if l > 0 {
+			kraft += 1 / float64(uint(1)<<l)
+		}
+	}
+	if kraft != 1 {
+		t.Errorf("expected a complete code, got a Kraft sum of %f", kraft)
+	}
+}
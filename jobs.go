@@ -0,0 +1,59 @@
+package bendis
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type jobsConfig struct {
+	mu   sync.RWMutex
+	jobs map[string]func()
+}
+
+// ScheduleJob runs job on the scheduler at the times given by spec, e.g. "@daily" or
+// "0 3 * * *", and registers it under name, so that bendis rpc jobs:run <name> can run it now
+func (b *Bendis) ScheduleJob(name, spec string, job func()) error {
+	_, err := b.Scheduler.AddFunc(spec, job)
+	if err != nil {
+		return fmt.Errorf("scheduling job %s: %w", name, err)
+	}
+
+	b.config.jobs.mu.Lock()
+	defer b.config.jobs.mu.Unlock()
+
+	if b.config.jobs.jobs == nil {
+		b.config.jobs.jobs = make(map[string]func())
+	}
+	b.config.jobs.jobs[name] = job
+
+	return nil
+}
+
+// RunJob runs a job added with ScheduleJob right away, and waits for it to finish
+func (b *Bendis) RunJob(name string) error {
+	b.config.jobs.mu.RLock()
+	job, ok := b.config.jobs.jobs[name]
+	b.config.jobs.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown job %s", name)
+	}
+
+	job()
+	return nil
+}
+
+// JobNames returns the names of the jobs added with ScheduleJob, sorted
+func (b *Bendis) JobNames() []string {
+	b.config.jobs.mu.RLock()
+	defer b.config.jobs.mu.RUnlock()
+
+	names := make([]string, 0, len(b.config.jobs.jobs))
+	for name := range b.config.jobs.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
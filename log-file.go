@@ -0,0 +1,64 @@
+package bendis
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// logFile is the file the loggers write to when LOG_FILE is set. It can be rotated while the
+// application runs.
+type logFile struct {
+	mu   sync.Mutex
+	name string
+	file *os.File
+}
+
+func openLogFile(name string) (*logFile, error) {
+	f := &logFile{name: name}
+	err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *logFile) open() error {
+	file, err := os.OpenFile(f.name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	return nil
+}
+
+func (f *logFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Write(p)
+}
+
+// rotate renames the log file after the current time, e.g. app.log.20220131-154500, and starts a
+// new one. It returns the name the old file was given.
+func (f *logFile) rotate() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rotated := fmt.Sprintf("%s.%s", f.name, time.Now().Format("20060102-150405"))
+	err := os.Rename(f.name, rotated)
+	if err != nil {
+		return "", err
+	}
+
+	old := f.file
+	err = f.open()
+	if err != nil {
+		// keep writing to the renamed file rather than losing lines
+		return "", err
+	}
+	_ = old.Close()
+
+	return rotated, nil
+}
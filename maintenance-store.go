@@ -0,0 +1,415 @@
+package bendis
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaintenanceStore keeps the maintenance window where every instance of the application can see
+// it, and survives restarts, along with the last reported state of each instance
+type MaintenanceStore interface {
+	Load() (*MaintenanceWindow, error)
+	Save(window *MaintenanceWindow) error // nil ends maintenance
+	Report(status InstanceStatus) error
+	Instances() ([]InstanceStatus, error)
+}
+
+// InstanceStatus is what an instance last reported about itself
+type InstanceStatus struct {
+	ID          string
+	Host        string
+	PID         int
+	Maintenance bool
+	Window      *MaintenanceWindow
+	SeenAt      time.Time
+}
+
+// instanceTimeout is how long an instance that stopped reporting is still listed
+const instanceTimeout = time.Hour
+
+// instanceHeartbeat is how often an instance reports its state when it has not changed, so that it
+// stays listed
+const instanceHeartbeat = instanceTimeout / 4
+
+// maintenanceReports remembers the state this instance last reported, so that it is only written to
+// the store again when it changes, or is about to be dropped from the list
+type maintenanceReports struct {
+	mu   sync.Mutex
+	last *InstanceStatus
+}
+
+// due reports whether status needs to be reported
+func (r *maintenanceReports) due(status InstanceStatus) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.last == nil || r.last.Maintenance != status.Maintenance || !sameWindow(r.last.Window, status.Window) ||
+		status.SeenAt.Sub(r.last.SeenAt) >= instanceHeartbeat
+}
+
+// reported remembers status as the last one the store has
+func (r *maintenanceReports) reported(status InstanceStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.last = &status
+}
+
+func sameWindow(a, b *MaintenanceWindow) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Start.Equal(b.Start) && a.End.Equal(b.End) && a.RetryAfter == b.RetryAfter &&
+		a.Secret == b.Secret && a.Message == b.Message
+}
+
+// createMaintenanceStore returns the store named by MAINTENANCE_STORE: redis, database, or file,
+// the default, which keeps the state in tmp/maintenance under the application's root
+func (b *Bendis) createMaintenanceStore() MaintenanceStore {
+	switch strings.ToLower(os.Getenv("MAINTENANCE_STORE")) {
+	case "redis":
+		pool := redisPool
+		if pool == nil {
+			pool = b.createRedisPool()
+			redisPool = pool
+		}
+		return &redisMaintenanceStore{Pool: pool, Prefix: b.config.redis.prefix}
+	case "database", "db":
+		if b.DB.Pool != nil {
+			return &databaseMaintenanceStore{DB: b.DB.Pool, DatabaseType: b.DB.DatabaseType}
+		}
+		b.ErrorLog.Println("MAINTENANCE_STORE is database, but there is no database; using a file")
+	}
+
+	return &fileMaintenanceStore{Dir: b.RootPath + "/tmp/maintenance"}
+}
+
+// redisMaintenanceStore keeps the window in a key, and instances in a hash
+type redisMaintenanceStore struct {
+	Pool   *redis.Pool
+	Prefix string
+}
+
+func (s *redisMaintenanceStore) key(name string) string {
+	return fmt.Sprintf("%s:maintenance:%s", s.Prefix, name)
+}
+
+func (s *redisMaintenanceStore) Load() (*MaintenanceWindow, error) {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	data, err := redis.Bytes(conn.Do("GET", s.key("window")))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeWindow(data)
+}
+
+func (s *redisMaintenanceStore) Save(window *MaintenanceWindow) error {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	if window == nil {
+		_, err := conn.Do("DEL", s.key("window"))
+		return err
+	}
+
+	data, err := json.Marshal(window)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Do("SET", s.key("window"), data)
+	return err
+}
+
+func (s *redisMaintenanceStore) Report(status InstanceStatus) error {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Do("HSET", s.key("instances"), status.ID, data)
+	return err
+}
+
+func (s *redisMaintenanceStore) Instances() ([]InstanceStatus, error) {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	entries, err := redis.StringMap(conn.Do("HGETALL", s.key("instances")))
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []InstanceStatus
+	for id, data := range entries {
+		var status InstanceStatus
+		if err := json.Unmarshal([]byte(data), &status); err != nil {
+			continue
+		}
+
+		if time.Since(status.SeenAt) > instanceTimeout {
+			_, _ = conn.Do("HDEL", s.key("instances"), id)
+			continue
+		}
+		instances = append(instances, status)
+	}
+
+	return instances, nil
+}
+
+// databaseMaintenanceStore keeps the window and the instances in the bendis_maintenance table,
+// which it creates when it is first needed. The window is the row with the id "window".
+type databaseMaintenanceStore struct {
+	DB           *sql.DB
+	DatabaseType string
+	mu           sync.Mutex
+	created      bool
+}
+
+func (s *databaseMaintenanceStore) createTable() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.created {
+		return nil
+	}
+
+	_, err := s.DB.Exec(`create table if not exists bendis_maintenance (
+		id varchar(255) primary key,
+		data text not null,
+		updated_at timestamp not null
+	)`)
+	if err != nil {
+		return err
+	}
+
+	s.created = true
+	return nil
+}
+
+func (s *databaseMaintenanceStore) placeholders(n int) []interface{} {
+	p := make([]interface{}, n)
+	for i := range p {
+		p[i] = placeholder(s.DatabaseType, i+1)
+	}
+	return p
+}
+
+func (s *databaseMaintenanceStore) put(id string, value interface{}) error {
+	if err := s.createTable(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	// a delete and an insert work the same on every database, unlike upserts
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("delete from bendis_maintenance where id = %s", s.placeholders(1)...), id)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("insert into bendis_maintenance (id, data, updated_at) values (%s, %s, %s)", s.placeholders(3)...),
+		id, string(data), time.Now())
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *databaseMaintenanceStore) Load() (*MaintenanceWindow, error) {
+	if err := s.createTable(); err != nil {
+		return nil, err
+	}
+
+	var data string
+	err := s.DB.QueryRow(fmt.Sprintf("select data from bendis_maintenance where id = %s", s.placeholders(1)...), "window").Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeWindow([]byte(data))
+}
+
+func (s *databaseMaintenanceStore) Save(window *MaintenanceWindow) error {
+	if window == nil {
+		if err := s.createTable(); err != nil {
+			return err
+		}
+		_, err := s.DB.Exec(fmt.Sprintf("delete from bendis_maintenance where id = %s", s.placeholders(1)...), "window")
+		return err
+	}
+
+	return s.put("window", window)
+}
+
+func (s *databaseMaintenanceStore) Report(status InstanceStatus) error {
+	return s.put("instance:"+status.ID, status)
+}
+
+func (s *databaseMaintenanceStore) Instances() ([]InstanceStatus, error) {
+	if err := s.createTable(); err != nil {
+		return nil, err
+	}
+
+	_, err := s.DB.Exec(fmt.Sprintf("delete from bendis_maintenance where id like 'instance:%%' and updated_at < %s", s.placeholders(1)...),
+		time.Now().Add(-instanceTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.Query("select data from bendis_maintenance where id like 'instance:%'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var instances []InstanceStatus
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var status InstanceStatus
+		if err := json.Unmarshal([]byte(data), &status); err == nil {
+			instances = append(instances, status)
+		}
+	}
+
+	return instances, rows.Err()
+}
+
+// fileMaintenanceStore keeps the window in window.json, and each instance in instances/<id>.json.
+// Instances on several hosts share it only if the directory is on a shared volume.
+type fileMaintenanceStore struct {
+	Dir string
+}
+
+func (s *fileMaintenanceStore) Load() (*MaintenanceWindow, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, "window.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeWindow(data)
+}
+
+func (s *fileMaintenanceStore) Save(window *MaintenanceWindow) error {
+	fileName := filepath.Join(s.Dir, "window.json")
+	if window == nil {
+		err := os.Remove(fileName)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return writeJSONFile(fileName, window)
+}
+
+func (s *fileMaintenanceStore) Report(status InstanceStatus) error {
+	return writeJSONFile(filepath.Join(s.Dir, "instances", status.ID+".json"), status)
+}
+
+func (s *fileMaintenanceStore) Instances() ([]InstanceStatus, error) {
+	files, err := filepath.Glob(filepath.Join(s.Dir, "instances", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []InstanceStatus
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		var status InstanceStatus
+		if err := json.Unmarshal(data, &status); err != nil {
+			continue
+		}
+
+		if time.Since(status.SeenAt) > instanceTimeout {
+			_ = os.Remove(file)
+			continue
+		}
+		instances = append(instances, status)
+	}
+
+	return instances, nil
+}
+
+// writeJSONFile writes value to a temporary file and renames it, so readers never see half of it.
+// Only the owner can read the file, as the window holds the bypass secret.
+func writeJSONFile(fileName string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(fileName), 0755)
+	if err != nil {
+		return err
+	}
+
+	// created with the mode 0600, and with a name of its own, as instances may write at the same time
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fileName)
+}
+
+func decodeWindow(data []byte) (*MaintenanceWindow, error) {
+	var window MaintenanceWindow
+	err := json.Unmarshal(data, &window)
+	if err != nil {
+		return nil, err
+	}
+	return &window, nil
+}
@@ -0,0 +1,201 @@
+package bendis
+
+import (
+	"database/sql"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newMaintenanceStores(t *testing.T) map[string]MaintenanceStore {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	return map[string]MaintenanceStore{
+		"file":     &fileMaintenanceStore{Dir: t.TempDir()},
+		"database": &databaseMaintenanceStore{DB: db, DatabaseType: "sqlite"},
+		"redis": &redisMaintenanceStore{
+			Pool: &redis.Pool{Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", s.Addr())
+			}},
+			Prefix: "test",
+		},
+	}
+}
+
+func TestMaintenanceStore(t *testing.T) {
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	window := &MaintenanceWindow{Start: start, RetryAfter: 60, Secret: "secret", Message: "upgrading"}
+
+	for name, store := range newMaintenanceStores(t) {
+		loaded, err := store.Load()
+		if err != nil || loaded != nil {
+			t.Errorf("%s: expected no window, got %v, %v", name, loaded, err)
+		}
+
+		if err = store.Save(window); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		loaded, err = store.Load()
+		if err != nil || loaded == nil || !loaded.Start.Equal(start) || loaded.Secret != "secret" || loaded.Message != "upgrading" {
+			t.Errorf("%s: expected the saved window, got %+v, %v", name, loaded, err)
+		}
+
+		if err = store.Save(nil); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+		loaded, err = store.Load()
+		if err != nil || loaded != nil {
+			t.Errorf("%s: expected the window to be ended, got %v, %v", name, loaded, err)
+		}
+
+		var reports = []InstanceStatus{
+			{ID: "web-1", Host: "web", PID: 1, Maintenance: true, SeenAt: time.Now()},
+			{ID: "web-1", Host: "web", PID: 1, SeenAt: time.Now()},
+			{ID: "web-2", Host: "web", PID: 2, SeenAt: time.Now()},
+		}
+		for _, status := range reports {
+			if err = store.Report(status); err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+		}
+
+		instances, err := store.Instances()
+		if err != nil || len(instances) != 2 {
+			t.Errorf("%s: expected 2 instances, got %d, %v", name, len(instances), err)
+		}
+		for _, status := range instances {
+			if status.Maintenance {
+				t.Errorf("%s: expected the last report of %s to replace the first", name, status.ID)
+			}
+		}
+	}
+}
+
+func TestMaintenanceStore_StaleInstances(t *testing.T) {
+	stores := newMaintenanceStores(t)
+
+	// the database store goes by when the row was written, so it is left stale by hand below
+	for name, store := range stores {
+		if err := store.Report(InstanceStatus{ID: "gone", SeenAt: time.Now().Add(-2 * instanceTimeout)}); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+	}
+
+	db := stores["database"].(*databaseMaintenanceStore)
+	if _, err := db.DB.Exec("update bendis_maintenance set updated_at = ?", time.Now().Add(-2*instanceTimeout)); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range stores {
+		for i := 0; i < 2; i++ {
+			instances, err := store.Instances()
+			if err != nil || len(instances) != 0 {
+				t.Errorf("%s: expected the stale instance to be dropped, got %d, %v", name, len(instances), err)
+			}
+		}
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(stores["file"].(*fileMaintenanceStore).Dir, "instances"))
+	if len(entries) != 0 {
+		t.Errorf("expected the stale instance file to be removed, got %d files", len(entries))
+	}
+}
+
+func TestFileMaintenanceStore_Private(t *testing.T) {
+	store := &fileMaintenanceStore{Dir: t.TempDir()}
+	if err := store.Save(&MaintenanceWindow{Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(store.Dir, "window.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %s", info.Mode().Perm())
+	}
+
+	entries, _ := os.ReadDir(store.Dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only window.json to be left, got %d entries", len(entries))
+	}
+}
+
+// countingStore counts the reports made to the store it wraps
+type countingStore struct {
+	MaintenanceStore
+	reports []InstanceStatus
+}
+
+func (s *countingStore) Report(status InstanceStatus) error {
+	s.reports = append(s.reports, status)
+	return s.MaintenanceStore.Report(status)
+}
+
+func TestBendis_SyncMaintenance(t *testing.T) {
+	defer setMaintenance(nil)
+
+	store := &countingStore{MaintenanceStore: &fileMaintenanceStore{Dir: t.TempDir()}}
+	b := &Bendis{
+		ErrorLog: log.New(io.Discard, "", 0),
+		config: config{maintenance: maintenanceConfig{
+			store:      store,
+			instanceID: "web-1",
+			reports:    &maintenanceReports{},
+		}},
+	}
+
+	var tests = []struct {
+		name    string
+		window  *MaintenanceWindow
+		reports int
+	}{
+		{"first sync", nil, 1},
+		{"nothing changed", nil, 1},
+		{"maintenance started", &MaintenanceWindow{Secret: "secret", Message: "upgrading"}, 2},
+		{"still in maintenance", &MaintenanceWindow{Secret: "secret", Message: "upgrading"}, 2},
+		{"message changed", &MaintenanceWindow{Secret: "secret", Message: "almost done"}, 3},
+		{"maintenance ended", nil, 4},
+	}
+
+	for _, e := range tests {
+		if err := store.Save(e.window); err != nil {
+			t.Fatal(err)
+		}
+		b.syncMaintenance()
+
+		if len(store.reports) != e.reports {
+			t.Errorf("%s: expected %d reports, got %d", e.name, e.reports, len(store.reports))
+		}
+	}
+
+	for _, status := range store.reports {
+		if status.Window != nil && status.Window.Secret != "" {
+			t.Error("expected the secret to be left out of the reported window")
+		}
+	}
+
+	// an unchanged instance still reports now and then, so that it stays listed
+	b.config.maintenance.reports.last.SeenAt = time.Now().Add(-instanceHeartbeat)
+	b.syncMaintenance()
+	if len(store.reports) != 5 {
+		t.Errorf("expected a heartbeat report, got %d reports", len(store.reports))
+	}
+}
@@ -0,0 +1,294 @@
+package bendis
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/zgoerbe/bendis/render"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maintenanceCookie = "bendis_maintenance"
+
+// MaintenanceWindow is a period during which the application answers with 503 Service Unavailable,
+// except to allowed addresses, exempt paths, and visitors who used the bypass url
+type MaintenanceWindow struct {
+	Start      time.Time // zero starts it right away
+	End        time.Time // zero lasts until the application is brought up again
+	RetryAfter int       // seconds; zero uses MAINTENANCE_RETRY_AFTER, or the time left until End
+	Secret     string    // visiting /<secret> sets a cookie that bypasses maintenance; empty uses MAINTENANCE_SECRET
+	Message    string    // shown on the maintenance page
+}
+
+// Active reports whether the window covers t
+func (m *MaintenanceWindow) Active(t time.Time) bool {
+	return (m.Start.IsZero() || !t.Before(m.Start)) && (m.End.IsZero() || t.Before(m.End))
+}
+
+type maintenanceConfig struct {
+	allow      []*net.IPNet
+	exempt     []string
+	secret     string
+	retryAfter int
+	poll       time.Duration
+	store      MaintenanceStore
+	instanceID string
+	reports    *maintenanceReports
+}
+
+// maintenance holds the current or next maintenance window, if there is one
+var maintenance struct {
+	sync.RWMutex
+	window *MaintenanceWindow
+}
+
+func setMaintenance(window *MaintenanceWindow) {
+	maintenance.Lock()
+	defer maintenance.Unlock()
+	maintenance.window = window
+}
+
+// currentMaintenance returns a copy of the maintenance window, or nil
+func currentMaintenance() *MaintenanceWindow {
+	maintenance.RLock()
+	defer maintenance.RUnlock()
+
+	if maintenance.window == nil {
+		return nil
+	}
+	window := *maintenance.window
+	return &window
+}
+
+// maintenanceSettings reads MAINTENANCE_ALLOW (addresses and CIDR ranges), MAINTENANCE_EXEMPT (path
+// globs), MAINTENANCE_SECRET, MAINTENANCE_RETRY_AFTER and MAINTENANCE_POLL (seconds between checks
+// of the store), all comma separated where they are lists
+func (b *Bendis) maintenanceSettings() maintenanceConfig {
+	host, _ := os.Hostname()
+
+	settings := maintenanceConfig{
+		exempt:     []string{"/public/maintenance.html"},
+		secret:     os.Getenv("MAINTENANCE_SECRET"),
+		retryAfter: 300,
+		poll:       5 * time.Second,
+		store:      b.createMaintenanceStore(),
+		instanceID: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		reports:    &maintenanceReports{},
+	}
+
+	settings.allow = b.networks("MAINTENANCE_ALLOW")
+
+	for _, glob := range strings.Split(os.Getenv("MAINTENANCE_EXEMPT"), ",") {
+		if glob = strings.TrimSpace(glob); glob != "" {
+			settings.exempt = append(settings.exempt, glob)
+		}
+	}
+
+	if seconds, err := strconv.Atoi(os.Getenv("MAINTENANCE_RETRY_AFTER")); err == nil && seconds > 0 {
+		settings.retryAfter = seconds
+	}
+
+	if seconds, err := strconv.Atoi(os.Getenv("MAINTENANCE_POLL")); err == nil && seconds > 0 {
+		settings.poll = time.Duration(seconds) * time.Second
+	}
+
+	return settings
+}
+
+// SetMaintenance starts, schedules or, given nil, ends maintenance on every instance that shares the
+// maintenance store. This instance changes right away; the others within MAINTENANCE_POLL seconds.
+func (b *Bendis) SetMaintenance(window *MaintenanceWindow) error {
+	var err error
+	if b.config.maintenance.store != nil {
+		// save first, so that the watcher cannot load the old state over the new one
+		err = b.config.maintenance.store.Save(window)
+	}
+
+	setMaintenance(window)
+	b.reportMaintenance()
+
+	return err
+}
+
+// MaintenanceStatus returns the state each instance sharing the maintenance store last reported
+func (b *Bendis) MaintenanceStatus() ([]InstanceStatus, error) {
+	if b.config.maintenance.store == nil {
+		return []InstanceStatus{b.instanceStatus()}, nil
+	}
+	return b.config.maintenance.store.Instances()
+}
+
+// watchMaintenance keeps this instance in step with the maintenance store, and reports its state
+func (b *Bendis) watchMaintenance() {
+	if b.config.maintenance.store == nil {
+		return
+	}
+
+	for {
+		b.syncMaintenance()
+		time.Sleep(b.config.maintenance.poll)
+	}
+}
+
+// syncMaintenance loads the window from the store, and reports this instance's state to it if that
+// changed
+func (b *Bendis) syncMaintenance() {
+	window, err := b.config.maintenance.store.Load()
+	if err != nil {
+		// keep the state we have; one failed read should not take the site down or bring it up
+		b.ErrorLog.Println("could not load maintenance state:", err)
+	} else {
+		setMaintenance(window)
+	}
+
+	b.reportMaintenance()
+}
+
+func (b *Bendis) reportMaintenance() {
+	if b.config.maintenance.store == nil {
+		return
+	}
+
+	status := b.instanceStatus()
+	reports := b.config.maintenance.reports
+	if reports != nil && !reports.due(status) {
+		return
+	}
+
+	err := b.config.maintenance.store.Report(status)
+	if err != nil {
+		b.ErrorLog.Println("could not report maintenance state:", err)
+		return
+	}
+
+	if reports != nil {
+		reports.reported(status)
+	}
+}
+
+// instanceStatus returns the state of this instance. The secret is left out of the window, as
+// anyone who can list the instances does not need it.
+func (b *Bendis) instanceStatus() InstanceStatus {
+	host, _ := os.Hostname()
+	window := currentMaintenance()
+	if window != nil {
+		window.Secret = ""
+	}
+
+	return InstanceStatus{
+		ID:          b.config.maintenance.instanceID,
+		Host:        host,
+		PID:         os.Getpid(),
+		Maintenance: window != nil && window.Active(time.Now()),
+		Window:      window,
+		SeenAt:      time.Now(),
+	}
+}
+
+// maintenanceBypassed reports whether r may pass during maintenance: it comes from an allowed
+// address, asks for an exempt path, or carries the bypass cookie
+func (b *Bendis) maintenanceBypassed(r *http.Request, secret string) bool {
+	if matchPath(b.config.maintenance.exempt, r.URL.Path) {
+		return true
+	}
+
+	// RemoteAddr is the peer, or the client a trusted proxy forwarded the request for
+	if containsIP(b.config.maintenance.allow, remoteIP(r.RemoteAddr)) {
+		return true
+	}
+
+	if secret != "" {
+		cookie, err := r.Cookie(maintenanceCookie)
+		if err == nil && hmac.Equal([]byte(cookie.Value), []byte(b.maintenanceToken(secret))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maintenanceToken is the value of the bypass cookie: it proves the secret was known, without
+// storing the secret itself in the browser
+func (b *Bendis) maintenanceToken(secret string) string {
+	mac := hmac.New(sha256.New, []byte(b.EncryptionKey))
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// setMaintenanceCookie lets the visitor through for the rest of the window, and sends them home
+func (b *Bendis) setMaintenanceCookie(w http.ResponseWriter, r *http.Request, window *MaintenanceWindow, secret string) {
+	secure, _ := strconv.ParseBool(b.config.cookie.secure)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     maintenanceCookie,
+		Value:    b.maintenanceToken(secret),
+		Path:     "/",
+		Domain:   b.config.cookie.domain,
+		Expires:  window.End,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// maintenanceResponse answers with 503: problem+json for clients that prefer JSON, the maintenance
+// view if there is one, public/maintenance.html, or a plain error page
+func (b *Bendis) maintenanceResponse(w http.ResponseWriter, r *http.Request, window *MaintenanceWindow) {
+	retryAfter := window.RetryAfter
+	if retryAfter == 0 && !window.End.IsZero() {
+		retryAfter = int(time.Until(window.End).Seconds()) + 1
+	}
+	if retryAfter <= 0 {
+		retryAfter = b.config.maintenance.retryAfter
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
+
+	if wantsJSON(r) {
+		err := b.WriteProblem(w, r, http.StatusServiceUnavailable, window.Message)
+		if err != nil {
+			b.ErrorLog.Println(err)
+		}
+		return
+	}
+
+	if b.Render != nil && b.errorViewExists("maintenance") {
+		td := &render.TemplateData{
+			StringMap: map[string]string{
+				"message":     window.Message,
+				"retry_after": strconv.Itoa(retryAfter),
+			},
+		}
+		if !window.End.IsZero() {
+			td.StringMap["ends_at"] = window.End.Format(time.RFC3339)
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		err := b.Render.Page(w, r, "maintenance", nil, td)
+		if err != nil {
+			b.ErrorLog.Println(err)
+		}
+		return
+	}
+
+	page, err := os.ReadFile(fmt.Sprintf("%s/public/maintenance.html", b.RootPath))
+	if err == nil {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write(page)
+		return
+	}
+
+	b.ErrorResponse(w, r, http.StatusServiceUnavailable)
+}
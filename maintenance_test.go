@@ -0,0 +1,179 @@
+package bendis
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBendis_CheckForMaintenanceMode(t *testing.T) {
+	defer setMaintenance(nil)
+
+	_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+	b := &Bendis{
+		RootPath:      t.TempDir(),
+		EncryptionKey: testKey,
+		ErrorLog:      log.New(io.Discard, "", 0),
+		config: config{maintenance: maintenanceConfig{
+			allow:      []*net.IPNet{allowed},
+			exempt:     []string{"/public/maintenance.html", "/health"},
+			secret:     "configured",
+			retryAfter: 300,
+		}},
+	}
+
+	var passed bool
+	handler := b.CheckForMaintenanceMode(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passed = true
+	}))
+
+	window := &MaintenanceWindow{Secret: "letmein", Message: "upgrading"}
+	token := b.maintenanceToken("letmein")
+
+	var tests = []struct {
+		name       string
+		window     *MaintenanceWindow
+		path       string
+		remoteAddr string
+		cookie     string
+		passed     bool
+		status     int
+	}{
+		{"no window", nil, "/", "192.0.2.1:1234", "", true, http.StatusOK},
+		{"window not started", &MaintenanceWindow{Start: time.Now().Add(time.Hour)}, "/", "192.0.2.1:1234", "", true, http.StatusOK},
+		{"window over", &MaintenanceWindow{End: time.Now().Add(-time.Hour)}, "/", "192.0.2.1:1234", "", true, http.StatusOK},
+		{"active", window, "/", "192.0.2.1:1234", "", false, http.StatusServiceUnavailable},
+		{"allowed address", window, "/", "10.1.2.3:1234", "", true, http.StatusOK},
+		{"exempt path", window, "/health", "192.0.2.1:1234", "", true, http.StatusOK},
+		{"bypass cookie", window, "/", "192.0.2.1:1234", token, true, http.StatusOK},
+		{"wrong cookie", window, "/", "192.0.2.1:1234", b.maintenanceToken("guess"), false, http.StatusServiceUnavailable},
+		{"cookie for the configured secret", window, "/", "192.0.2.1:1234", b.maintenanceToken("configured"), false, http.StatusServiceUnavailable},
+		{"configured secret", &MaintenanceWindow{}, "/", "192.0.2.1:1234", b.maintenanceToken("configured"), true, http.StatusOK},
+		{"bypass url", window, "/letmein", "192.0.2.1:1234", "", false, http.StatusSeeOther},
+	}
+
+	for _, e := range tests {
+		setMaintenance(e.window)
+		passed = false
+
+		r := httptest.NewRequest("GET", e.path, nil)
+		r.RemoteAddr = e.remoteAddr
+		if e.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: maintenanceCookie, Value: e.cookie})
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if passed != e.passed {
+			t.Errorf("%s: expected passed to be %t, got %t", e.name, e.passed, passed)
+		}
+		if w.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, w.Code)
+		}
+	}
+
+	// the bypass url sets the cookie that lets the visitor through
+	setMaintenance(window)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/letmein", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != maintenanceCookie || cookies[0].Value != token || !cookies[0].HttpOnly {
+		t.Errorf("expected the bypass cookie, got %v", cookies)
+	}
+	if w.Header().Get("Location") != "/" {
+		t.Errorf("expected a redirect home, got %q", w.Header().Get("Location"))
+	}
+}
+
+func TestBendis_MaintenanceResponse(t *testing.T) {
+	b := &Bendis{
+		RootPath: t.TempDir(),
+		ErrorLog: log.New(io.Discard, "", 0),
+		config:   config{maintenance: maintenanceConfig{retryAfter: 300}},
+	}
+
+	var tests = []struct {
+		name        string
+		window      *MaintenanceWindow
+		accept      string
+		retryAfter  string
+		contentType string
+	}{
+		{"default retry after", &MaintenanceWindow{}, "", "300", "text/html; charset=utf-8"},
+		{"window retry after", &MaintenanceWindow{RetryAfter: 60}, "", "60", "text/html; charset=utf-8"},
+		{"until the end", &MaintenanceWindow{End: time.Now().Add(90 * time.Second)}, "", "90", "text/html; charset=utf-8"},
+		{"json", &MaintenanceWindow{Message: "upgrading"}, "application/json", "300", "application/problem+json"},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", e.accept)
+		w := httptest.NewRecorder()
+		b.maintenanceResponse(w, r, e.window)
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected status 503, got %d", e.name, w.Code)
+		}
+		if w.Header().Get("Retry-After") != e.retryAfter {
+			t.Errorf("%s: expected Retry-After %s, got %s", e.name, e.retryAfter, w.Header().Get("Retry-After"))
+		}
+		if w.Header().Get("Content-Type") != e.contentType {
+			t.Errorf("%s: expected content type %s, got %s", e.name, e.contentType, w.Header().Get("Content-Type"))
+		}
+	}
+
+	// public/maintenance.html is served when there is no maintenance view
+	if err := os.MkdirAll(b.RootPath+"/public", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b.RootPath+"/public/maintenance.html", []byte("back soon"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	b.maintenanceResponse(w, httptest.NewRequest("GET", "/", nil), &MaintenanceWindow{})
+	if w.Body.String() != "back soon" {
+		t.Errorf("expected the maintenance page, got %q", w.Body.String())
+	}
+}
+
+func TestBendis_MaintenanceSettings(t *testing.T) {
+	t.Setenv("MAINTENANCE_STORE", "")
+	t.Setenv("MAINTENANCE_ALLOW", "192.0.2.1, 10.0.0.0/8")
+	t.Setenv("MAINTENANCE_EXEMPT", "/health, /api/status")
+	t.Setenv("MAINTENANCE_RETRY_AFTER", "120")
+	t.Setenv("MAINTENANCE_POLL", "not a number")
+
+	b := &Bendis{RootPath: t.TempDir(), ErrorLog: log.New(io.Discard, "", 0)}
+	settings := b.maintenanceSettings()
+
+	var tests = []struct {
+		ip       string
+		expected bool
+	}{
+		{"192.0.2.1", true},
+		{"192.0.2.2", false},
+		{"10.9.8.7", true},
+	}
+
+	for _, e := range tests {
+		if got := containsIP(settings.allow, net.ParseIP(e.ip)); got != e.expected {
+			t.Errorf("%s: expected allowed to be %t, got %t", e.ip, e.expected, got)
+		}
+	}
+
+	if len(settings.exempt) != 3 || settings.exempt[2] != "/api/status" {
+		t.Errorf("expected the maintenance page and two exempt paths, got %v", settings.exempt)
+	}
+	if settings.retryAfter != 120 || settings.poll != 5*time.Second {
+		t.Errorf("unexpected settings: %+v", settings)
+	}
+	if _, ok := settings.store.(*fileMaintenanceStore); !ok {
+		t.Errorf("expected the file store by default, got %T", settings.store)
+	}
+}
@@ -1,46 +1,81 @@
 package bendis
 
 import (
-	"fmt"
 	"github.com/justinas/nosurf"
+	"github.com/zgoerbe/bendis/session"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func (b *Bendis) SessionLoad(next http.Handler) http.Handler {
 	b.InfoLog.Println("SessionLoad called")
+
+	// cookie sessions are written by the store's own middleware, as the data is the cookie
+	if store, ok := b.Session.Store.(*session.CookieStore); ok {
+		return store.LoadAndSave(b.Session, next)
+	}
 	return b.Session.LoadAndSave(next)
 }
 
+// NoSurf is middleware that requires a CSRF token with every unsafe request that is not exempt. The
+// token can be sent in the csrf_token form field, the X-CSRF-Token header or the header named by
+// CSRF_HEADER, e.g. by htmx or fetch.
 func (b *Bendis) NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
 	secure, _ := strconv.ParseBool(b.config.cookie.secure)
 
-	csrfHandler.ExemptGlob("/api/*")
+	csrfHandler.ExemptFunc(b.csrfExempt)
+	csrfHandler.SetFailureHandler(http.HandlerFunc(b.csrfFailure))
 
 	csrfHandler.SetBaseCookie(http.Cookie{
-		Path:     "/",
+		Path:     b.config.csrf.cookiePath,
 		Domain:   b.config.cookie.domain,
 		Secure:   secure,
 		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
+		SameSite: b.config.csrf.sameSite,
 	})
 
-	return csrfHandler
+	header := b.config.csrf.header
+	if header == "" || strings.EqualFold(header, nosurf.HeaderName) {
+		return csrfHandler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// nosurf only reads its own header
+		if token := r.Header.Get(header); token != "" && r.Header.Get(nosurf.HeaderName) == "" {
+			r.Header.Set(nosurf.HeaderName, token)
+		}
+		csrfHandler.ServeHTTP(w, r)
+	})
 }
 
+// CheckForMaintenanceMode answers with 503 Service Unavailable while a maintenance window is active,
+// except for requests that may bypass it. Visiting /<secret> sets the bypass cookie.
 func (b *Bendis) CheckForMaintenanceMode(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		if maintenanceMode {
-			if !strings.Contains(request.URL.Path, "/public/maintenance.html") {
-				writer.WriteHeader(http.StatusServiceUnavailable)
-				writer.Header().Set("Retry-After:", "300")
-				writer.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, post-check=0, pre-check=0")
-				http.ServeFile(writer, request, fmt.Sprintf("%s/public/maintenance.html", b.RootPath))
-				return
-			}
+		window := currentMaintenance()
+		if window == nil || !window.Active(time.Now()) {
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		secret := window.Secret
+		if secret == "" {
+			secret = b.config.maintenance.secret
 		}
-		next.ServeHTTP(writer, request)
+
+		if secret != "" && request.URL.Path == "/"+secret {
+			b.setMaintenanceCookie(writer, request, window, secret)
+			return
+		}
+
+		if b.maintenanceBypassed(request, secret) {
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		b.maintenanceResponse(writer, request, window)
 	})
 }
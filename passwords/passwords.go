@@ -0,0 +1,183 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Hasher hashes passwords with argon2id or bcrypt, and verifies hashes made with either, so that
+// applications can move from one to the other, or raise the cost, as users log in
+type Hasher struct {
+	Algorithm  string // argon2id (the default) or bcrypt
+	Memory     uint32 // argon2id memory in KiB
+	Time       uint32 // argon2id iterations
+	Threads    uint8  // argon2id parallelism
+	KeyLength  uint32
+	SaltLength uint32
+	BcryptCost int
+}
+
+// Default is the hasher used by the package level functions. Bendis applications configure it from
+// the .env file.
+var Default = New()
+
+// New returns a Hasher using argon2id with the parameters recommended by RFC 9106 for systems with
+// limited memory
+func New() *Hasher {
+	return &Hasher{
+		Algorithm:  Argon2id,
+		Memory:     64 * 1024,
+		Time:       3,
+		Threads:    2,
+		KeyLength:  32,
+		SaltLength: 16,
+		BcryptCost: 12,
+	}
+}
+
+// Hash hashes password with the default hasher
+func Hash(password string) (string, error) {
+	return Default.Hash(password)
+}
+
+// Verify checks password against hash with the default hasher
+func Verify(password, hash string) (bool, error) {
+	return Default.Verify(password, hash)
+}
+
+// NeedsRehash reports whether hash should be replaced, using the default hasher
+func NeedsRehash(hash string) bool {
+	return Default.NeedsRehash(hash)
+}
+
+// Hash returns a hash of password in the PHC string format for argon2id, e.g.
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>, or the usual format for bcrypt
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks password against hash, which may be an argon2id or a bcrypt hash. A wrong password
+// is not an error; an error means the hash could not be checked.
+func (h *Hasher) Verify(password, hash string) (bool, error) {
+	if isBcrypt(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	params, salt, key, err := decodeArgon2(hash)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or other parameters than h
+// uses now. Hash the password again after a successful login when it does.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if isBcrypt(hash) {
+		if h.Algorithm != Bcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.BcryptCost
+	}
+
+	if h.Algorithm == Bcrypt {
+		return true
+	}
+
+	params, salt, key, err := decodeArgon2(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.Memory || params.Time != h.Time || params.Threads != h.Threads ||
+		uint32(len(key)) != h.KeyLength || uint32(len(salt)) != h.SaltLength
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// decodeArgon2 splits a PHC string into its parameters, salt and key
+func decodeArgon2(hash string) (Hasher, []byte, []byte, error) {
+	var params Hasher
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	// argon2 panics without a pass or a thread
+	if params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	// an empty key would match any password
+	if len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,99 @@
+package passwords
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+// a cheap hasher, so the tests run quickly
+func testHasher() *Hasher {
+	h := New()
+	h.Memory = 1024
+	h.Time = 1
+	h.BcryptCost = bcrypt.MinCost
+	return h
+}
+
+func TestHasher_Argon2id(t *testing.T) {
+	h := testHasher()
+
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := h.Verify("correct horse", hash)
+	if err != nil || !ok {
+		t.Error("password did not verify:", err)
+	}
+
+	ok, err = h.Verify("wrong horse", hash)
+	if err != nil || ok {
+		t.Error("wrong password verified:", err)
+	}
+
+	if h.NeedsRehash(hash) {
+		t.Error("fresh hash needs a rehash")
+	}
+
+	h.Time = 2
+	if !h.NeedsRehash(hash) {
+		t.Error("hash with old parameters does not need a rehash")
+	}
+}
+
+func TestHasher_Bcrypt(t *testing.T) {
+	h := testHasher()
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := h.Verify("secret", string(legacy))
+	if err != nil || !ok {
+		t.Error("bcrypt hash did not verify:", err)
+	}
+
+	if !h.NeedsRehash(string(legacy)) {
+		t.Error("bcrypt hash does not need a rehash to argon2id")
+	}
+
+	h.Algorithm = Bcrypt
+	if h.NeedsRehash(string(legacy)) {
+		t.Error("bcrypt hash with the configured cost needs a rehash")
+	}
+
+	hash, err := h.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBcrypt(hash) {
+		t.Error("expected a bcrypt hash, got", hash)
+	}
+}
+
+func TestHasher_Verify_UnknownHash(t *testing.T) {
+	_, err := testHasher().Verify("secret", "plain")
+	if err != ErrUnknownHash {
+		t.Error("expected ErrUnknownHash, got", err)
+	}
+}
+
+func TestHasher_Verify_InvalidArgon2(t *testing.T) {
+	var tests = []struct {
+		name string
+		hash string
+	}{
+		{"empty key", "$argon2id$v=19$m=1024,t=1,p=2$c2FsdHNhbHRzYWx0c2FsdA$"},
+		{"no passes", "$argon2id$v=19$m=1024,t=0,p=2$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5"},
+		{"no threads", "$argon2id$v=19$m=1024,t=1,p=0$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5"},
+	}
+
+	for _, e := range tests {
+		ok, err := testHasher().Verify("any password", e.hash)
+		if ok || err != ErrUnknownHash {
+			t.Errorf("%s: expected ErrUnknownHash, got %t and %v", e.name, ok, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package bendis
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"html"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Problem is an RFC 7807 problem details object, the body of API error responses
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// WriteProblem writes an application/problem+json response with the given status, and detail as
+// the human readable explanation of what went wrong
+func (b *Bendis) WriteProblem(w http.ResponseWriter, r *http.Request, status int, detail string, headers ...http.Header) error {
+	return b.WriteProblemDetails(w, r, Problem{Status: status, Detail: detail}, headers...)
+}
+
+// WriteProblemDetails writes problem as an application/problem+json response. The type defaults to
+// about:blank, the title to the text for the status, and the instance and request ID are taken
+// from the request.
+func (b *Bendis) WriteProblemDetails(w http.ResponseWriter, r *http.Request, problem Problem, headers ...http.Header) error {
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+
+	if problem.Instance == "" {
+		problem.Instance = r.URL.Path
+	}
+
+	if problem.RequestID == "" {
+		problem.RequestID = middleware.GetReqID(r.Context())
+	}
+
+	return b.writeJSON(w, problem.Status, problem, "application/problem+json", headers...)
+}
+
+// ValidationProblem writes a 422 problem+json response listing the errors of v by field
+func (b *Bendis) ValidationProblem(w http.ResponseWriter, r *http.Request, v *Validation) error {
+	return b.WriteProblemDetails(w, r, Problem{
+		Status: http.StatusUnprocessableEntity,
+		Detail: "The request contains invalid fields",
+		Errors: v.Errors,
+	})
+}
+
+// ErrorResponse writes an error page for status, or a problem+json response when the client
+// prefers JSON. Pages are rendered from views/errors/<status> if that template exists.
+func (b *Bendis) ErrorResponse(w http.ResponseWriter, r *http.Request, status int) {
+	if wantsJSON(r) {
+		err := b.WriteProblem(w, r, status, "")
+		if err != nil {
+			b.ErrorLog.Println(err)
+		}
+		return
+	}
+
+	view := fmt.Sprintf("errors/%d", status)
+	if b.Render != nil && b.errorViewExists(view) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		err := b.Render.Page(w, r, view, nil, nil)
+		if err != nil {
+			b.ErrorLog.Println(err)
+		}
+		return
+	}
+
+	text := html.EscapeString(http.StatusText(status))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<!doctype html>\n<html><head><title>%d %s</title></head><body><h1>%d %s</h1></body></html>\n", status, text, status, text)
+}
+
+// errorViewExists reports whether there is a template for view for the configured renderer
+func (b *Bendis) errorViewExists(view string) bool {
+	ext := ".jet"
+	if strings.ToLower(b.Render.Renderer) == "go" {
+		ext = ".gohtml"
+	}
+
+	_, err := os.Stat(fmt.Sprintf("%s/views/%s%s", b.RootPath, view, ext))
+	return err == nil
+}
+
+// wantsJSON reports whether the client prefers JSON over HTML, going by the Accept header
+func wantsJSON(r *http.Request) bool {
+	var htmlQuality, jsonQuality float64
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+
+		switch {
+		case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+			if quality > htmlQuality {
+				htmlQuality = quality
+			}
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			if quality > jsonQuality {
+				jsonQuality = quality
+			}
+		}
+	}
+
+	return jsonQuality > htmlQuality
+}
@@ -0,0 +1,122 @@
+package bendis
+
+import (
+	"encoding/json"
+	"github.com/zgoerbe/bendis/render"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWantsJSON(t *testing.T) {
+	var tests = []struct {
+		accept   string
+		expected bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/html", false},
+		{"application/json", true},
+		{"application/problem+json", true},
+		{"text/html, application/json", false},
+		{"text/html;q=0.9, application/json", true},
+		{"application/json;q=0.5, text/html;q=0.8", false},
+		{"text/html;q=0, application/json;q=0.1", true},
+		{"application/xhtml+xml, application/json;q=0.9", false},
+		{"not a media type, application/json", true},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", e.accept)
+		if got := wantsJSON(r); got != e.expected {
+			t.Errorf("%q: expected %t, got %t", e.accept, e.expected, got)
+		}
+	}
+}
+
+func TestBendis_WriteProblem(t *testing.T) {
+	b := &Bendis{}
+
+	r := httptest.NewRequest("GET", "/users/7", nil)
+	w := httptest.NewRecorder()
+	err := b.WriteProblem(w, r, http.StatusNotFound, "No such user", http.Header{"X-Extra": {"yes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "application/problem+json" || w.Header().Get("X-Extra") != "yes" {
+		t.Errorf("unexpected headers: %v", w.Header())
+	}
+
+	var problem Problem
+	if err = json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Problem{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound, Detail: "No such user", Instance: "/users/7"}
+	if problem.Type != expected.Type || problem.Title != expected.Title || problem.Status != expected.Status ||
+		problem.Detail != expected.Detail || problem.Instance != expected.Instance {
+		t.Errorf("expected %+v, got %+v", expected, problem)
+	}
+
+	// a problem without a status is a server error
+	w = httptest.NewRecorder()
+	if err = b.WriteProblemDetails(w, r, Problem{Type: "https://example.com/broken"}); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "https://example.com/broken") {
+		t.Errorf("expected a 500 keeping the type, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBendis_ErrorResponse(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(root+"/views/errors", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(root+"/views/errors/404.gohtml", []byte("custom not found"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name        string
+		render      *render.Render
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"json", nil, "application/json", http.StatusNotFound, "application/problem+json", `"title": "Not Found"`},
+		{"html", nil, "text/html", http.StatusNotFound, "text/html; charset=utf-8", "<h1>404 Not Found</h1>"},
+		{"no accept header", nil, "", http.StatusForbidden, "text/html; charset=utf-8", "<h1>403 Forbidden</h1>"},
+		{"error view", &render.Render{Renderer: "go", RootPath: root}, "text/html", http.StatusNotFound, "text/html; charset=utf-8", "custom not found"},
+		{"no error view", &render.Render{Renderer: "go", RootPath: root}, "text/html", http.StatusGone, "text/html; charset=utf-8", "<h1>410 Gone</h1>"},
+	}
+
+	for _, e := range tests {
+		b := &Bendis{RootPath: root, Render: e.render, ErrorLog: log.New(io.Discard, "", 0)}
+
+		r := httptest.NewRequest("GET", "/missing", nil)
+		r.Header.Set("Accept", e.accept)
+		w := httptest.NewRecorder()
+		b.ErrorResponse(w, r, e.status)
+
+		if w.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, w.Code)
+		}
+		if w.Header().Get("Content-Type") != e.contentType {
+			t.Errorf("%s: expected content type %s, got %s", e.name, e.contentType, w.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(w.Body.String(), e.body) {
+			t.Errorf("%s: expected the body to contain %q, got %q", e.name, e.body, w.Body.String())
+		}
+	}
+}
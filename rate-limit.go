@@ -0,0 +1,151 @@
+package bendis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/zgoerbe/bendis/cache"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit allows Limit requests per Window for each key, such as a client address or a user. It
+// counts with a sliding window: the count of the previous window is weighted by how much of it
+// still overlaps the last Window, so there are no bursts of twice the limit at window boundaries.
+type RateLimit struct {
+	Name   string        // keeps the counters of different limits apart, e.g. login or api
+	Limit  int           // requests allowed per window
+	Window time.Duration // e.g. time.Minute
+	// Key returns who the limit applies to; it defaults to RateLimitByIP, and an empty key falls
+	// back to the client address
+	Key func(r *http.Request) string
+	// Cache keeps the counters; it defaults to the application's cache, or memory if it cannot
+	// count, which only limits each instance on its own
+	Cache cache.Cache
+}
+
+// RateLimitByIP limits each client address. That is the peer the request came from; behind a proxy,
+// set TRUSTED_PROXIES so that the RealIP middleware puts the client it forwarded for in its place.
+func RateLimitByIP(r *http.Request) string {
+	if ip := remoteIP(r.RemoteAddr); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// RateLimitByToken limits each bearer token, or API key in the X-API-Key header
+func RateLimitByToken(r *http.Request) string {
+	token := r.Header.Get("X-API-Key")
+	if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		token = strings.TrimPrefix(authorization, "Bearer ")
+	}
+	if token == "" {
+		return ""
+	}
+
+	// the token itself is not put into the cache
+	hash := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(hash[:])
+}
+
+// RateLimitByUser limits each logged in user, by the userID in the session
+func (b *Bendis) RateLimitByUser(r *http.Request) string {
+	if b.Session == nil || !b.Session.Exists(r.Context(), "userID") {
+		return ""
+	}
+	return fmt.Sprintf("user:%v", b.Session.Get(r.Context(), "userID"))
+}
+
+// RateLimit returns middleware that answers with 429 Too Many Requests once a key goes over the
+// limit, e.g. for the login form
+//
+//	r.With(app.RateLimit(bendis.RateLimit{Name: "login", Limit: 5, Window: time.Minute})).Post("/users/login", h.PostUserLogin)
+//
+// Every response carries RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and
+// refused ones Retry-After. If the cache cannot be reached, requests are let through.
+func (b *Bendis) RateLimit(limit RateLimit) func(http.Handler) http.Handler {
+	if limit.Key == nil {
+		limit.Key = RateLimitByIP
+	}
+	if limit.Window <= 0 {
+		limit.Window = time.Minute
+	}
+
+	counter, ok := limit.Cache.(cache.Counter)
+	if limit.Cache == nil {
+		counter, ok = b.Cache.(cache.Counter)
+	}
+	if !ok {
+		counter = cache.NewMemoryCache()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := limit.Key(r)
+			if key == "" {
+				key = RateLimitByIP(r)
+			}
+
+			remaining, reset, err := limit.take(counter, key)
+			if err != nil {
+				b.ErrorLog.Println("rate limit:", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			seconds := int(math.Ceil(reset.Seconds()))
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Limit, int(limit.Window.Seconds())))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds))
+
+			if remaining < 0 {
+				w.Header().Set("RateLimit-Remaining", "0")
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				b.ErrorResponse(w, r, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// take counts a request for key, and returns how many more are allowed, which is negative if this
+// one is not, and how long until one more is
+func (l RateLimit) take(counter cache.Counter, key string) (int, time.Duration, error) {
+	now := time.Now()
+	window := now.UnixNano() / int64(l.Window)
+	elapsed := time.Duration(now.UnixNano() % int64(l.Window))
+
+	prefix := fmt.Sprintf("ratelimit:%s:%s", l.Name, key)
+	expires := int(math.Ceil((2 * l.Window).Seconds()))
+
+	current, err := counter.Increment(fmt.Sprintf("%s:%d", prefix, window), expires)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	previous, err := counter.Count(fmt.Sprintf("%s:%d", prefix, window-1))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	weight := 1 - float64(elapsed)/float64(l.Window)
+	count := int(math.Floor(float64(previous)*weight)) + current
+
+	reset := l.Window - elapsed
+	if count > l.Limit && previous > 0 && current <= l.Limit {
+		// the requests of the previous window are what is in the way; they drop out gradually
+		over := float64(count - l.Limit)
+		reset = time.Duration(over / float64(previous) * float64(l.Window))
+		if reset < time.Second {
+			reset = time.Second
+		}
+	}
+
+	return l.Limit - count, reset, nil
+}
@@ -0,0 +1,146 @@
+package bendis
+
+import (
+	"bytes"
+	"errors"
+	"github.com/zgoerbe/bendis/cache"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// failingCounter is a cache that cannot be reached
+type failingCounter struct {
+	cache.Cache
+}
+
+func (failingCounter) Increment(string, int) (int, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (failingCounter) Count(string) (int, error) {
+	return 0, errors.New("connection refused")
+}
+
+func rateLimitRequest(handler http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/users/login", nil)
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestBendis_RateLimit(t *testing.T) {
+	b := &Bendis{ErrorLog: log.New(io.Discard, "", 0)}
+	counters := cache.NewMemoryCache()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	login := b.RateLimit(RateLimit{Name: "login", Limit: 2, Window: time.Hour, Cache: counters})(next)
+
+	var tests = []struct {
+		name       string
+		remoteAddr string
+		status     int
+		remaining  string
+	}{
+		{"first", "192.0.2.1:1234", http.StatusOK, "1"},
+		{"second", "192.0.2.1:1234", http.StatusOK, "0"},
+		{"over the limit", "192.0.2.1:1234", http.StatusTooManyRequests, "0"},
+		{"from another port", "192.0.2.1:5678", http.StatusTooManyRequests, "0"},
+		{"another client", "192.0.2.2:1234", http.StatusOK, "1"},
+	}
+
+	for _, e := range tests {
+		w := rateLimitRequest(login, e.remoteAddr)
+
+		if w.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, w.Code)
+		}
+		if w.Header().Get("RateLimit-Remaining") != e.remaining {
+			t.Errorf("%s: expected %s remaining, got %q", e.name, e.remaining, w.Header().Get("RateLimit-Remaining"))
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Policy") != "2;w=3600" {
+			t.Errorf("%s: unexpected limit headers %q and %q", e.name, w.Header().Get("RateLimit-Limit"), w.Header().Get("RateLimit-Policy"))
+		}
+
+		reset, err := strconv.Atoi(w.Header().Get("RateLimit-Reset"))
+		if err != nil || reset < 1 || reset > 3600 {
+			t.Errorf("%s: expected a reset within the window, got %q", e.name, w.Header().Get("RateLimit-Reset"))
+		}
+
+		retryAfter := w.Header().Get("Retry-After")
+		if e.status == http.StatusTooManyRequests && retryAfter != strconv.Itoa(reset) {
+			t.Errorf("%s: expected Retry-After to be %d, got %q", e.name, reset, retryAfter)
+		}
+		if e.status == http.StatusOK && retryAfter != "" {
+			t.Errorf("%s: expected no Retry-After, got %q", e.name, retryAfter)
+		}
+	}
+
+	// another limit in the same cache counts apart
+	api := b.RateLimit(RateLimit{Name: "api", Limit: 2, Window: time.Hour, Cache: counters})(next)
+	if w := rateLimitRequest(api, "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected another limit to let the client through, got %d", w.Code)
+	}
+}
+
+func TestBendis_RateLimit_Key(t *testing.T) {
+	b := &Bendis{ErrorLog: log.New(io.Discard, "", 0)}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	handler := b.RateLimit(RateLimit{Limit: 1, Window: time.Hour, Cache: cache.NewMemoryCache(), Key: RateLimitByToken})(next)
+
+	var tests = []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"token", "abc", http.StatusOK},
+		{"same token", "abc", http.StatusTooManyRequests},
+		{"other token", "def", http.StatusOK},
+		// without a token, the client address is limited instead
+		{"no token", "", http.StatusOK},
+		{"no token again", "", http.StatusTooManyRequests},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("GET", "/api/users", nil)
+		if e.token != "" {
+			r.Header.Set("Authorization", "Bearer "+e.token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, w.Code)
+		}
+	}
+}
+
+func TestBendis_RateLimit_CounterFails(t *testing.T) {
+	var logged bytes.Buffer
+	b := &Bendis{ErrorLog: log.New(&logged, "", 0)}
+
+	served := 0
+	handler := b.RateLimit(RateLimit{Limit: 1, Cache: failingCounter{}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+
+	for i := 0; i < 3; i++ {
+		w := rateLimitRequest(handler, "192.0.2.1:1234")
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "" {
+			t.Errorf("expected the request to pass without headers, got %d and %v", w.Code, w.Header())
+		}
+	}
+
+	if served != 3 {
+		t.Errorf("expected 3 requests to be served, got %d", served)
+	}
+	if logged.Len() == 0 {
+		t.Error("expected the error to be logged")
+	}
+}
@@ -0,0 +1,85 @@
+package bendis
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIP is middleware that sets RemoteAddr to the client address a proxy forwarded the request for,
+// from X-Forwarded-For or X-Real-IP. The headers are only honoured when the request comes from one
+// of TRUSTED_PROXIES, as anyone else can send them to pose as another address; without trusted
+// proxies, RemoteAddr is left alone.
+func (b *Bendis) RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxies := b.config.proxies
+		if len(proxies) > 0 && containsIP(proxies, remoteIP(r.RemoteAddr)) {
+			if ip := forwardedIP(r, proxies); ip != nil {
+				r.RemoteAddr = ip.String()
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedIP returns the client address in the forwarding headers of r. X-Forwarded-For is read
+// from the right, skipping the trusted proxies, since the addresses in front of them may be made up.
+func forwardedIP(r *http.Request, proxies []*net.IPNet) net.IP {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		addresses := strings.Split(forwarded, ",")
+		for i := len(addresses) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(addresses[i]))
+			if ip == nil {
+				return nil
+			}
+			if i == 0 || !containsIP(proxies, ip) {
+				return ip
+			}
+		}
+	}
+
+	return net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+}
+
+// remoteIP returns the address of a RemoteAddr, which may or may not have a port
+func remoteIP(remoteAddr string) net.IP {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// networks reads the comma separated addresses and CIDR ranges in the environment variable key
+func (b *Bendis) networks(key string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range envList(key) {
+		if !strings.Contains(entry, "/") {
+			if strings.Contains(entry, ":") {
+				entry += "/128"
+			} else {
+				entry += "/32"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			b.ErrorLog.Printf("invalid %s entry: %s", key, entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,90 @@
+package bendis
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBendis_RealIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	b := &Bendis{config: config{proxies: []*net.IPNet{proxies}}}
+
+	var tests = []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		expected   string
+	}{
+		{"no proxy", "203.0.113.7:4000", "", "", "203.0.113.7:4000"},
+		{"spoofed by a client", "203.0.113.7:4000", "127.0.0.1", "", "203.0.113.7:4000"},
+		{"forwarded by a proxy", "10.0.0.2:4000", "198.51.100.1", "", "198.51.100.1"},
+		{"through two proxies", "10.0.0.2:4000", "198.51.100.1, 10.0.0.3", "", "198.51.100.1"},
+		{"made up in front of the client", "10.0.0.2:4000", "127.0.0.1, 198.51.100.1", "", "198.51.100.1"},
+		{"x-real-ip", "10.0.0.2:4000", "", "198.51.100.2", "198.51.100.2"},
+		{"garbage", "10.0.0.2:4000", "not-an-address", "", "10.0.0.2:4000"},
+	}
+
+	for _, e := range tests {
+		var remoteAddr string
+		handler := b.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			remoteAddr = r.RemoteAddr
+		}))
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = e.remoteAddr
+		if e.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", e.forwarded)
+		}
+		if e.realIP != "" {
+			r.Header.Set("X-Real-IP", e.realIP)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if remoteAddr != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, remoteAddr)
+		}
+	}
+}
+
+func TestBendis_MaintenanceBypassed(t *testing.T) {
+	_, local, _ := net.ParseCIDR("127.0.0.1/32")
+	b := &Bendis{config: config{maintenance: maintenanceConfig{
+		allow:  []*net.IPNet{local},
+		exempt: []string{"/public/maintenance.html", "/health/*"},
+	}}}
+
+	var tests = []struct {
+		name       string
+		path       string
+		remoteAddr string
+		forwarded  string
+		bypassed   bool
+	}{
+		{"allowed address", "/", "127.0.0.1:4000", "", true},
+		{"other address", "/", "203.0.113.7:4000", "", false},
+		{"spoofed address", "/", "203.0.113.7:4000", "127.0.0.1", false},
+		{"exempt path", "/public/maintenance.html", "203.0.113.7:4000", "", true},
+		{"nested exempt path", "/health/db/ping", "203.0.113.7:4000", "", true},
+	}
+
+	for _, e := range tests {
+		var bypassed bool
+		handler := b.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bypassed = b.maintenanceBypassed(r, "")
+		}))
+
+		r := httptest.NewRequest("GET", e.path, nil)
+		r.RemoteAddr = e.remoteAddr
+		if e.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", e.forwarded)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if bypassed != e.bypassed {
+			t.Errorf("%s: expected bypassed to be %t", e.name, e.bypassed)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package render
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/justinas/nosurf"
@@ -30,6 +31,7 @@ type TemplateData struct {
 	FloatMap        map[string]float32
 	Data            map[string]interface{}
 	CSRFToken       string
+	CSPNonce        string
 	Port            string
 	ServerName      string
 	Secure          bool
@@ -37,10 +39,25 @@ type TemplateData struct {
 	Flash           string
 }
 
+type nonceKey struct{}
+
+// WithNonce returns r carrying the Content-Security-Policy nonce of the response, which pages get
+// as CSPNonce, to put into <script nonce="..."> and <style nonce="..."> tags
+func WithNonce(r *http.Request, nonce string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), nonceKey{}, nonce))
+}
+
+// Nonce returns the Content-Security-Policy nonce of r, or an empty string if it has none
+func Nonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(nonceKey{}).(string)
+	return nonce
+}
+
 func (b *Render) defaultData(td *TemplateData, r *http.Request) *TemplateData {
 	td.Secure = b.Secure
 	td.ServerName = b.ServerName
 	td.CSRFToken = nosurf.Token(r)
+	td.CSPNonce = Nonce(r)
 	td.Port = b.Port
 	if b.Session.Exists(r.Context(), "userID") {
 		td.IsAuthenticated = true
@@ -76,6 +93,8 @@ func (b *Render) GoPage(w http.ResponseWriter, r *http.Request, view string, dat
 	if data != nil {
 		td = data.(*TemplateData)
 	}
+	td.CSRFToken = nosurf.Token(r)
+	td.CSPNonce = Nonce(r)
 
 	err = tmpl.Execute(w, &td)
 	if err != nil {
@@ -75,4 +75,20 @@ func TestReder_JetPage(t *testing.T) {
 	if err != nil {
 		t.Error("Error rendering page", err)
 	}
-}
\ No newline at end of file
+}
+
+func TestNonce(t *testing.T) {
+	r, err := http.NewRequest("GET", "/url", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if Nonce(r) != "" {
+		t.Error("request without a nonce has one")
+	}
+
+	r = WithNonce(r, "abc")
+	if Nonce(r) != "abc" {
+		t.Error("wrong nonce; expected abc, got", Nonce(r))
+	}
+}
@@ -30,6 +30,11 @@ func (b *Bendis) ReadJSON(w http.ResponseWriter, r *http.Request, data interface
 }
 
 func (b *Bendis) WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
+	return b.writeJSON(w, status, data, "application/json", headers...)
+}
+
+// writeJSON writes data as JSON with the given content type, e.g. application/problem+json
+func (b *Bendis) writeJSON(w http.ResponseWriter, status int, data interface{}, contentType string, headers ...http.Header) error {
 	out, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
 		return err
@@ -41,7 +46,7 @@ func (b *Bendis) WriteJSON(w http.ResponseWriter, status int, data interface{},
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
 
 	_, err = w.Write(out)
@@ -83,22 +88,25 @@ func (b *Bendis) DownloadFile(w http.ResponseWriter, r *http.Request, pathToFile
 	return nil
 }
 
+// Error404 writes a not found page, or a problem+json response to clients that prefer JSON
 func (b *Bendis) Error404(w http.ResponseWriter, r *http.Request) {
-	b.ErrorStatus(w, http.StatusNotFound)
+	b.ErrorResponse(w, r, http.StatusNotFound)
 }
 
+// Error500 writes an internal server error page, or a problem+json response to clients that prefer JSON
 func (b *Bendis) Error500(w http.ResponseWriter, r *http.Request) {
-	b.ErrorStatus(w, http.StatusInternalServerError)
+	b.ErrorResponse(w, r, http.StatusInternalServerError)
 }
 
 func (b *Bendis) ErrorUnauthorized(w http.ResponseWriter, r *http.Request) {
-	b.ErrorStatus(w, http.StatusUnauthorized)
+	b.ErrorResponse(w, r, http.StatusUnauthorized)
 }
 
 func (b *Bendis) ErrorForbidden(w http.ResponseWriter, r *http.Request) {
-	b.ErrorStatus(w, http.StatusForbidden)
+	b.ErrorResponse(w, r, http.StatusForbidden)
 }
 
+// ErrorStatus writes the text for status as plain text. Use ErrorResponse when the request is at hand.
 func (b *Bendis) ErrorStatus(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
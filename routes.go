@@ -10,11 +10,13 @@ import (
 func (b *Bendis) routes() http.Handler {
 	mux := chi.NewRouter()
 	mux.Use(middleware.RequestID)
-	mux.Use(middleware.RealIP)
+	mux.Use(b.RealIP)
 	if b.Debug {
 		mux.Use(middleware.Logger)
 	}
 	mux.Use(middleware.Recoverer)
+	mux.Use(b.CORS)
+	mux.Use(b.SecurityHeaders)
 	mux.Use(b.SessionLoad)
 	mux.Use(b.NoSurf)
 	mux.Use(b.CheckForMaintenanceMode)
@@ -0,0 +1,378 @@
+package bendis
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RPCCommand is an admin command run with bendis rpc <command> [args]. The returned string is shown
+// to whoever ran it.
+type RPCCommand func(args []string) (string, error)
+
+type rpcCommand struct {
+	description string
+	run         RPCCommand
+}
+
+type rpcConfig struct {
+	mu       sync.RWMutex
+	commands map[string]rpcCommand
+}
+
+// RPCRequest is a command and its arguments, sent by bendis rpc
+type RPCRequest struct {
+	Command string
+	Args    []string
+}
+
+// rpcHandshakeTimeout limits how long a client has to answer the challenge
+const rpcHandshakeTimeout = 10 * time.Second
+
+// RegisterRPC adds an admin command, e.g. one that runs a scheduled job right away. Registering a
+// name again replaces the command.
+func (b *Bendis) RegisterRPC(name, description string, command RPCCommand) {
+	b.config.rpc.mu.Lock()
+	defer b.config.rpc.mu.Unlock()
+
+	if b.config.rpc.commands == nil {
+		b.config.rpc.commands = make(map[string]rpcCommand)
+	}
+	b.config.rpc.commands[name] = rpcCommand{description: description, run: command}
+}
+
+// registerRPCCommands adds the commands every application has
+func (b *Bendis) registerRPCCommands() {
+	b.RegisterRPC("help", "list the available commands", func(args []string) (string, error) {
+		b.config.rpc.mu.RLock()
+		defer b.config.rpc.mu.RUnlock()
+
+		names := make([]string, 0, len(b.config.rpc.commands))
+		for name := range b.config.rpc.commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var help strings.Builder
+		for _, name := range names {
+			help.WriteString(fmt.Sprintf("%-24s %s\n", name, b.config.rpc.commands[name].description))
+		}
+		return help.String(), nil
+	})
+
+	b.RegisterRPC("cache:clear", "empty the cache, or only the keys matching a pattern", func(args []string) (string, error) {
+		if b.Cache == nil {
+			return "", errors.New("no cache configured")
+		}
+
+		if len(args) > 0 {
+			return fmt.Sprintf("Emptied cache keys matching %s", args[0]), b.Cache.EmptyByMatch(args[0])
+		}
+		return "Emptied cache", b.Cache.Empty()
+	})
+
+	b.RegisterRPC("templates:reload", "parse jet templates again, to pick up changes", func(args []string) (string, error) {
+		if b.config.templates == nil {
+			return "Templates are not cached in debug mode", nil
+		}
+
+		b.config.templates.clear()
+		return "Reloaded templates", nil
+	})
+
+	b.RegisterRPC("logs:rotate", "rename the log file set in LOG_FILE, and start a new one", func(args []string) (string, error) {
+		if b.config.logFile == nil {
+			return "", errors.New("logs go to stdout; set LOG_FILE to rotate them")
+		}
+
+		rotated, err := b.config.logFile.rotate()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Rotated logs to %s", rotated), nil
+	})
+
+	b.RegisterRPC("jobs:run", "run a scheduled job now, or list them", func(args []string) (string, error) {
+		if len(args) == 0 {
+			names := b.JobNames()
+			if len(names) == 0 {
+				return "No jobs scheduled", nil
+			}
+			return strings.Join(names, "\n"), nil
+		}
+
+		err := b.RunJob(args[0])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Ran job %s", args[0]), nil
+	})
+}
+
+// RPCServer is reached by the bendis command line tool
+type RPCServer struct {
+	app *Bendis
+}
+
+// Run runs a registered command
+func (r *RPCServer) Run(req RPCRequest, resp *string) error {
+	r.app.config.rpc.mu.RLock()
+	command, ok := r.app.config.rpc.commands[req.Command]
+	r.app.config.rpc.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown command %s; try help", req.Command)
+	}
+
+	out, err := command.run(req.Args)
+	if err != nil {
+		return err
+	}
+	*resp = out
+	return nil
+}
+
+func (r *RPCServer) MaintenanceMode(inMaintenanceMode bool, resp *string) error {
+	if inMaintenanceMode {
+		if err := r.app.SetMaintenance(&MaintenanceWindow{}); err != nil {
+			return fmt.Errorf("in maintenance mode, but only this instance: %w", err)
+		}
+		*resp = "Server in maintenance mode"
+	} else {
+		if err := r.app.SetMaintenance(nil); err != nil {
+			return fmt.Errorf("live, but only this instance: %w", err)
+		}
+		*resp = "Server live!"
+	}
+	return nil
+}
+
+// ScheduleMaintenance sets a maintenance window, which may start and end in the future
+func (r *RPCServer) ScheduleMaintenance(window MaintenanceWindow, resp *string) error {
+	if !window.End.IsZero() && !window.End.After(window.Start) {
+		return errors.New("maintenance must end after it starts")
+	}
+
+	if err := r.app.SetMaintenance(&window); err != nil {
+		return fmt.Errorf("maintenance set, but only on this instance: %w", err)
+	}
+
+	switch {
+	case window.Start.After(time.Now()):
+		*resp = fmt.Sprintf("Maintenance scheduled from %s", window.Start.Format(time.RFC1123))
+	default:
+		*resp = "Server in maintenance mode"
+	}
+	if !window.End.IsZero() {
+		*resp = fmt.Sprintf("%s until %s", *resp, window.End.Format(time.RFC1123))
+	}
+	return nil
+}
+
+// Status returns the maintenance state of every instance
+func (r *RPCServer) Status(_ bool, resp *[]InstanceStatus) error {
+	instances, err := r.app.MaintenanceStatus()
+	if err != nil {
+		return err
+	}
+	*resp = instances
+	return nil
+}
+
+// RPCAddress returns where the RPC server listens: the unix socket RPC_SOCKET, or RPC_HOST (by
+// default 127.0.0.1) and RPC_PORT over TCP. The network is empty if neither is set.
+func RPCAddress() (string, string) {
+	if socket := os.Getenv("RPC_SOCKET"); socket != "" {
+		return "unix", socket
+	}
+
+	if port := os.Getenv("RPC_PORT"); port != "" {
+		host := os.Getenv("RPC_HOST")
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		return "tcp", net.JoinHostPort(host, port)
+	}
+
+	return "", ""
+}
+
+func (b *Bendis) listenRPC() {
+	// if nothing specified for rpc port or socket, don't start
+	network, address := RPCAddress()
+	if network == "" {
+		return
+	}
+
+	secret := []byte(os.Getenv("RPC_SECRET"))
+	if len(secret) == 0 {
+		b.ErrorLog.Println("not starting RPC server: RPC_SECRET is not set")
+		return
+	}
+
+	server := rpc.NewServer()
+	err := server.Register(&RPCServer{app: b})
+	if err != nil {
+		b.ErrorLog.Println(err)
+		return
+	}
+
+	b.InfoLog.Println("Starting RPC server on", network, address)
+	var listen net.Listener
+	if network == "unix" {
+		listen, err = listenUnix(address)
+	} else {
+		listen, err = net.Listen(network, address)
+	}
+	if err != nil {
+		b.ErrorLog.Println(err)
+		return
+	}
+
+	for {
+		rpcConn, err := listen.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func(conn net.Conn) {
+			if err := rpcHandshake(conn, secret); err != nil {
+				b.ErrorLog.Println("rejected RPC connection from", conn.RemoteAddr(), err)
+				_ = conn.Close()
+				return
+			}
+			server.ServeConn(conn)
+		}(rpcConn)
+	}
+}
+
+// listenUnix listens on a unix socket that only the owner can connect to. The socket is created
+// in a new folder only the owner can enter, and moved to address once its mode is set, so there
+// is no moment in which others could connect to it.
+func listenUnix(address string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(address), ".rpc-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "rpc.sock")
+	listen, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	// the socket is moved away, so there is nothing to remove when the listener closes
+	listen.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	err = os.Chmod(socket, 0600)
+	if err == nil {
+		// a socket left behind by a previous run is replaced
+		err = os.Rename(socket, address)
+	}
+	if err != nil {
+		_ = listen.Close()
+		return nil, err
+	}
+
+	return listen, nil
+}
+
+// rpcHandshake sends the client a random challenge, and expects the HMAC of it with the shared
+// secret back, so that only clients that know RPC_SECRET can run commands
+func rpcHandshake(conn net.Conn, secret []byte) error {
+	_ = conn.SetDeadline(time.Now().Add(rpcHandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "%s\n", hex.EncodeToString(challenge)); err != nil {
+		return err
+	}
+
+	// read byte by byte, so nothing after the answer is taken from the connection
+	answer, err := readLine(conn)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(answer), []byte(rpcMAC(secret, challenge))) {
+		_, _ = fmt.Fprint(conn, "DENIED\n")
+		return errors.New("wrong handshake")
+	}
+
+	_, err = fmt.Fprint(conn, "OK\n")
+	return err
+}
+
+// DialRPC connects to the RPC server of an application, and answers its challenge with secret
+func DialRPC(network, address string, secret []byte) (*rpc.Client, error) {
+	conn, err := net.DialTimeout(network, address, rpcHandshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(rpcHandshakeTimeout))
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	challenge, err := hex.DecodeString(strings.TrimSpace(line))
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.New("unexpected handshake from rpc server")
+	}
+
+	if _, err := fmt.Fprintf(conn, "%s\n", rpcMAC(secret, challenge)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	reply, err := r.ReadString('\n')
+	if err != nil || strings.TrimSpace(reply) != "OK" {
+		_ = conn.Close()
+		return nil, errors.New("rpc server rejected the handshake; check RPC_SECRET")
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return rpc.NewClient(conn), nil
+}
+
+func rpcMAC(secret, challenge []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(challenge)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func readLine(conn net.Conn) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for len(line) < 256 {
+		if _, err := conn.Read(b); err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return string(line), nil
+		}
+		line = append(line, b[0])
+	}
+	return "", errors.New("handshake line too long")
+}
@@ -0,0 +1,267 @@
+package bendis
+
+import (
+	"github.com/robfig/cron/v3"
+	"github.com/zgoerbe/bendis/cache"
+	"io"
+	"log"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newRPCTestApp(t *testing.T) *Bendis {
+	b := &Bendis{
+		RootPath:  t.TempDir(),
+		InfoLog:   log.New(io.Discard, "", 0),
+		ErrorLog:  log.New(io.Discard, "", 0),
+		Scheduler: cron.New(),
+	}
+	b.registerRPCCommands()
+	return b
+}
+
+// serveRPC serves b's commands on a unix socket in a temporary folder, and returns its path
+func serveRPC(t *testing.T, b *Bendis, secret string) string {
+	address := filepath.Join(t.TempDir(), "rpc.sock")
+	listen, err := listenUnix(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listen.Close() })
+
+	server := rpc.NewServer()
+	_ = server.Register(&RPCServer{app: b})
+
+	go func() {
+		for {
+			conn, err := listen.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				if rpcHandshake(conn, []byte(secret)) != nil {
+					_ = conn.Close()
+					return
+				}
+				server.ServeConn(conn)
+			}()
+		}
+	}()
+
+	return address
+}
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+	address := filepath.Join(dir, "rpc.sock")
+
+	// a socket left behind by a previous run
+	if err := os.WriteFile(address, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	listen, err := listenUnix(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listen.Close()
+
+	info, err := os.Stat(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a socket with mode 0600, got %s", info.Mode())
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the socket to be left, got %d entries", len(entries))
+	}
+
+	conn, err := net.Dial("unix", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+}
+
+func TestBendis_ListenRPC_NoSecret(t *testing.T) {
+	b := newRPCTestApp(t)
+	address := filepath.Join(t.TempDir(), "rpc.sock")
+	t.Setenv("RPC_SOCKET", address)
+	t.Setenv("RPC_SECRET", "")
+
+	// returns right away rather than serving
+	b.listenRPC()
+
+	if _, err := os.Stat(address); !os.IsNotExist(err) {
+		t.Errorf("expected no socket without a secret, got %v", err)
+	}
+}
+
+func TestRPC_Handshake(t *testing.T) {
+	b := newRPCTestApp(t)
+	address := serveRPC(t, b, "secret")
+
+	_, err := DialRPC("unix", address, []byte("wrong"))
+	if err == nil {
+		t.Error("expected the wrong secret to be rejected")
+	}
+
+	c, err := DialRPC("unix", address, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var help string
+	if err = c.Call("RPCServer.Run", RPCRequest{Command: "help"}, &help); err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{"cache:clear", "jobs:run", "logs:rotate", "templates:reload"} {
+		if !strings.Contains(help, command) {
+			t.Errorf("help does not list %s", command)
+		}
+	}
+
+	err = c.Call("RPCServer.Run", RPCRequest{Command: "nope"}, &help)
+	if err == nil {
+		t.Error("expected an error for an unknown command")
+	}
+}
+
+func TestRPC_Commands(t *testing.T) {
+	b := newRPCTestApp(t)
+
+	var ran int
+	if err := b.ScheduleJob("report", "@daily", func() { ran++ }); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ScheduleJob("broken", "not a spec", func() {}); err == nil {
+		t.Error("expected an error for an invalid spec")
+	}
+
+	logFile, err := openLogFile(filepath.Join(b.RootPath, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.InfoLog = log.New(logFile, "", 0)
+
+	var tests = []struct {
+		name     string
+		command  string
+		args     []string
+		setup    func()
+		expected string
+		failed   bool
+	}{
+		{"list jobs", "jobs:run", nil, nil, "report", false},
+		{"run a job", "jobs:run", []string{"report"}, nil, "Ran job report", false},
+		{"unknown job", "jobs:run", []string{"nope"}, nil, "", true},
+		{"templates in debug mode", "templates:reload", nil, nil, "Templates are not cached in debug mode", false},
+		{"templates", "templates:reload", nil, func() { b.config.templates = &templateCache{} }, "Reloaded templates", false},
+		{"logs to stdout", "logs:rotate", nil, nil, "", true},
+		{"logs", "logs:rotate", nil, func() { b.config.logFile = logFile }, "Rotated logs to", false},
+		{"no cache", "cache:clear", nil, nil, "", true},
+	}
+
+	server := &RPCServer{app: b}
+	for _, e := range tests {
+		if e.setup != nil {
+			e.setup()
+		}
+
+		var out string
+		err := server.Run(RPCRequest{Command: e.command, Args: e.args}, &out)
+		if (err != nil) != e.failed {
+			t.Errorf("%s: expected failed to be %t, got %v", e.name, e.failed, err)
+		}
+		if !strings.HasPrefix(out, e.expected) {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, out)
+		}
+	}
+
+	if ran != 1 {
+		t.Errorf("expected the job to run once, got %d", ran)
+	}
+}
+
+func TestLogFile_Rotate(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	f, err := openLogFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.New(f, "", 0)
+	logger.Println("before")
+
+	rotated, err := f.rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Println("after")
+
+	var tests = []struct {
+		file     string
+		expected string
+	}{
+		{rotated, "before\n"},
+		{name, "after\n"},
+	}
+
+	for _, e := range tests {
+		data, err := os.ReadFile(e.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.file, e.expected, data)
+		}
+	}
+}
+
+func TestBendis_New_RPCCommands(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(root+"/.env", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// .env does not override variables that are set already
+	t.Setenv("LOG_FILE", "logs/app.log")
+	t.Setenv("CACHE", "badger")
+	t.Setenv("DEBUG", "false")
+
+	var b Bendis
+	if err := b.New(root); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Cache.(*cache.BadgerCache).Conn.Close()
+
+	var tests = []struct {
+		command  string
+		args     []string
+		expected string
+	}{
+		{"logs:rotate", nil, "Rotated logs to " + root + "/logs/app.log."},
+		{"jobs:run", nil, "badger:gc"},
+		{"jobs:run", []string{"badger:gc"}, "Ran job badger:gc"},
+		{"templates:reload", nil, "Reloaded templates"},
+	}
+
+	server := &RPCServer{app: &b}
+	for _, e := range tests {
+		var out string
+		if err := server.Run(RPCRequest{Command: e.command, Args: e.args}, &out); err != nil {
+			t.Errorf("%s: %s", e.command, err)
+		}
+		if !strings.HasPrefix(out, e.expected) {
+			t.Errorf("%s: expected %q, got %q", e.command, e.expected, out)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ClamAV scans content with a clamd daemon, using the INSTREAM command
+type ClamAV struct {
+	Network   string // tcp or unix; defaults to tcp
+	Address   string // e.g. 127.0.0.1:3310 or /var/run/clamav/clamd.ctl
+	Timeout   time.Duration
+	ChunkSize int
+}
+
+// Scan streams r to clamd, and interprets its reply
+func (c *ClamAV) Scan(r io.Reader) error {
+	network := c.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	chunkSize := c.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 64 * 1024
+	}
+
+	conn, err := net.DialTimeout(network, c.Address, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	_, err = conn.Write([]byte("zINSTREAM\x00"))
+	if err != nil {
+		return err
+	}
+
+	// every chunk is prefixed with its length as a 4 byte big endian integer; a zero length ends the stream
+	buf := make([]byte, chunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	return parseReply(reply)
+}
+
+// Ping checks that clamd is reachable
+func (c *ClamAV) Ping() error {
+	network := c.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	conn, err := net.DialTimeout(network, c.Address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if strings.TrimRight(reply, "\x00\n") != "PONG" {
+		return fmt.Errorf("unexpected reply from clamd: %q", reply)
+	}
+	return nil
+}
+
+// parseReply turns a clamd reply such as "stream: OK" or "stream: Eicar-Signature FOUND" into an error
+func parseReply(reply string) error {
+	reply = strings.TrimRight(reply, "\x00\n")
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &InfectedError{Signature: strings.TrimSuffix(reply, " FOUND")}
+	case strings.HasSuffix(reply, " ERROR"):
+		return errors.New("clamd: " + strings.TrimSuffix(reply, " ERROR"))
+	}
+
+	return fmt.Errorf("unexpected reply from clamd: %q", reply)
+}
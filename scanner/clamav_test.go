@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// startClamdStub starts a minimal clamd that understands INSTREAM and PING, and reports
+// any stream containing "EICAR" as infected
+func startClamdStub(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func serveClamd(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch cmd {
+	case "zPING\x00":
+		_, _ = conn.Write([]byte("PONG\x00"))
+	case "zINSTREAM\x00":
+		var data bytes.Buffer
+		size := make([]byte, 4)
+		for {
+			if _, err := io.ReadFull(r, size); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size)
+			if n == 0 {
+				break
+			}
+			if _, err := io.CopyN(&data, r, int64(n)); err != nil {
+				return
+			}
+		}
+		if strings.Contains(data.String(), "EICAR") {
+			_, _ = conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		} else {
+			_, _ = conn.Write([]byte("stream: OK\x00"))
+		}
+	default:
+		_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
+
+func TestClamAV_Scan(t *testing.T) {
+	clam := ClamAV{Address: startClamdStub(t), ChunkSize: 4}
+
+	if err := clam.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := clam.Scan(strings.NewReader("a perfectly normal document"))
+	if err != nil {
+		t.Error("clean content reported as:", err)
+	}
+
+	err = clam.Scan(strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!"))
+	var infected *InfectedError
+	if !errors.As(err, &infected) {
+		t.Fatal("expected an infected error, got", err)
+	}
+
+	if infected.Signature != "Eicar-Test-Signature" {
+		t.Error("wrong signature:", infected.Signature)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	if err := parseReply("stream: Size limit exceeded ERROR\x00"); err == nil || errors.As(err, new(*InfectedError)) {
+		t.Error("expected a plain error, got", err)
+	}
+}
@@ -0,0 +1,21 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+)
+
+// Scanner is the interface for malware scanners. Scan returns nil if the content is clean, an
+// *InfectedError if something was found, and any other error if the content could not be scanned.
+type Scanner interface {
+	Scan(r io.Reader) error
+}
+
+// InfectedError is returned by a Scanner when the scanned content is infected
+type InfectedError struct {
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	return fmt.Sprintf("infected file: %s", e.Signature)
+}
@@ -0,0 +1,155 @@
+package bendis
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/zgoerbe/bendis/render"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SecurityHeadersOptions are the security headers sent with every response. An empty string
+// leaves a header out.
+type SecurityHeadersOptions struct {
+	HSTSMaxAge            int    // seconds; HSTS is sent only when the server is secure, and zero leaves it out
+	HSTSIncludeSubdomains bool   // whether HSTS covers subdomains too
+	HSTSPreload           bool   // whether the domain may be put on the browsers' preload lists
+	ContentTypeOptions    string // X-Content-Type-Options
+	FrameOptions          string // X-Frame-Options
+	ReferrerPolicy        string // Referrer-Policy
+	PermissionsPolicy     string // Permissions-Policy
+	CrossOriginOpener     string // Cross-Origin-Opener-Policy
+	ContentSecurityPolicy string // {nonce} is replaced with the nonce of the request
+	CSPReportOnly         bool   // report violations of the policy, without enforcing it
+}
+
+// DefaultSecurityHeaders are used for headers that are not set in .env. There is no default
+// Content-Security-Policy, since a policy has to fit what the pages load; one allowing only
+// scripts and styles of our own, and inline ones with the nonce, would be
+//
+//	default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'; frame-ancestors 'self'
+var DefaultSecurityHeaders = SecurityHeadersOptions{
+	HSTSMaxAge:         31536000,
+	ContentTypeOptions: "nosniff",
+	FrameOptions:       "SAMEORIGIN",
+	ReferrerPolicy:     "strict-origin-when-cross-origin",
+	PermissionsPolicy:  "camera=(), microphone=(), geolocation=(), payment=()",
+	CrossOriginOpener:  "same-origin",
+}
+
+// securityHeadersSettings reads HSTS_MAX_AGE, HSTS_INCLUDE_SUBDOMAINS, HSTS_PRELOAD, FRAME_OPTIONS,
+// REFERRER_POLICY, PERMISSIONS_POLICY, CROSS_ORIGIN_OPENER_POLICY, CONTENT_SECURITY_POLICY and
+// CSP_REPORT_ONLY. Headers that are not set keep their default, and off leaves one out.
+func (b *Bendis) securityHeadersSettings() SecurityHeadersOptions {
+	options := DefaultSecurityHeaders
+
+	if maxAge := os.Getenv("HSTS_MAX_AGE"); maxAge != "" {
+		options.HSTSMaxAge, _ = strconv.Atoi(maxAge)
+	}
+	options.HSTSIncludeSubdomains, _ = strconv.ParseBool(os.Getenv("HSTS_INCLUDE_SUBDOMAINS"))
+	options.HSTSPreload, _ = strconv.ParseBool(os.Getenv("HSTS_PRELOAD"))
+	options.CSPReportOnly, _ = strconv.ParseBool(os.Getenv("CSP_REPORT_ONLY"))
+
+	for key, header := range map[string]*string{
+		"FRAME_OPTIONS":              &options.FrameOptions,
+		"REFERRER_POLICY":            &options.ReferrerPolicy,
+		"PERMISSIONS_POLICY":         &options.PermissionsPolicy,
+		"CROSS_ORIGIN_OPENER_POLICY": &options.CrossOriginOpener,
+		"CONTENT_SECURITY_POLICY":    &options.ContentSecurityPolicy,
+	} {
+		value := strings.TrimSpace(os.Getenv(key))
+		switch {
+		case strings.EqualFold(value, "off"):
+			*header = ""
+		case value != "":
+			*header = value
+		}
+	}
+
+	return options
+}
+
+// SecurityHeaders is middleware that sends the security headers set in .env, and gives every
+// request a Content-Security-Policy nonce, which pages get as CSPNonce
+func (b *Bendis) SecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.securityHeaders(w, r, next, b.config.security)
+	})
+}
+
+// SecurityHeadersWith returns middleware that sends the headers in options, for route groups that
+// need others than the rest of the application
+func (b *Bendis) SecurityHeadersWith(options SecurityHeadersOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b.securityHeaders(w, r, next, options)
+		})
+	}
+}
+
+func (b *Bendis) securityHeaders(w http.ResponseWriter, r *http.Request, next http.Handler, options SecurityHeadersOptions) {
+	// keep the nonce of the request if it has one, so that the page and the header agree
+	nonce := render.Nonce(r)
+	if nonce == "" {
+		var err error
+		nonce, err = newNonce()
+		if err != nil {
+			b.ErrorLog.Println(err)
+			b.ErrorResponse(w, r, http.StatusInternalServerError)
+			return
+		}
+		r = render.WithNonce(r, nonce)
+	}
+
+	h := w.Header()
+
+	if options.HSTSMaxAge > 0 && (b.Server.Secure || r.TLS != nil) {
+		hsts := fmt.Sprintf("max-age=%d", options.HSTSMaxAge)
+		if options.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if options.HSTSPreload {
+			hsts += "; preload"
+		}
+		h.Set("Strict-Transport-Security", hsts)
+	}
+
+	for header, value := range map[string]string{
+		"X-Content-Type-Options":     options.ContentTypeOptions,
+		"X-Frame-Options":            options.FrameOptions,
+		"Referrer-Policy":            options.ReferrerPolicy,
+		"Permissions-Policy":         options.PermissionsPolicy,
+		"Cross-Origin-Opener-Policy": options.CrossOriginOpener,
+	} {
+		if value != "" {
+			h.Set(header, value)
+		}
+	}
+
+	if options.ContentSecurityPolicy != "" {
+		header := "Content-Security-Policy"
+		if options.CSPReportOnly {
+			header = "Content-Security-Policy-Report-Only"
+		}
+		h.Set(header, strings.ReplaceAll(options.ContentSecurityPolicy, "{nonce}", nonce))
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+// CSPNonce returns the Content-Security-Policy nonce of a request that passed the SecurityHeaders
+// middleware, for handlers that write html themselves
+func CSPNonce(r *http.Request) string {
+	return render.Nonce(r)
+}
+
+func newNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
@@ -0,0 +1,128 @@
+package bendis
+
+import (
+	"encoding/base64"
+	"github.com/zgoerbe/bendis/render"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBendis_SecurityHeadersWith(t *testing.T) {
+	csp := DefaultSecurityHeaders
+	csp.ContentSecurityPolicy = "script-src 'nonce-{nonce}'"
+
+	reportOnly := csp
+	reportOnly.CSPReportOnly = true
+
+	hsts := DefaultSecurityHeaders
+	hsts.HSTSIncludeSubdomains = true
+	hsts.HSTSPreload = true
+
+	var tests = []struct {
+		name     string
+		options  SecurityHeadersOptions
+		secure   bool
+		expected map[string]string
+	}{
+		{
+			"defaults",
+			DefaultSecurityHeaders,
+			false,
+			map[string]string{
+				"X-Content-Type-Options":     "nosniff",
+				"X-Frame-Options":            "SAMEORIGIN",
+				"Referrer-Policy":            "strict-origin-when-cross-origin",
+				"Permissions-Policy":         "camera=(), microphone=(), geolocation=(), payment=()",
+				"Cross-Origin-Opener-Policy": "same-origin",
+				"Strict-Transport-Security":  "",
+				"Content-Security-Policy":    "",
+			},
+		},
+		{"hsts when secure", DefaultSecurityHeaders, true, map[string]string{"Strict-Transport-Security": "max-age=31536000"}},
+		{"hsts with subdomains and preload", hsts, true, map[string]string{"Strict-Transport-Security": "max-age=31536000; includeSubDomains; preload"}},
+		{"header left out", SecurityHeadersOptions{FrameOptions: "DENY"}, true, map[string]string{"X-Frame-Options": "DENY", "X-Content-Type-Options": "", "Strict-Transport-Security": ""}},
+		{"csp", csp, false, map[string]string{"Content-Security-Policy": "script-src 'nonce-{nonce}'"}},
+		{"csp report only", reportOnly, false, map[string]string{"Content-Security-Policy-Report-Only": "script-src 'nonce-{nonce}'", "Content-Security-Policy": ""}},
+	}
+
+	for _, e := range tests {
+		b := &Bendis{Server: Server{Secure: e.secure}}
+
+		var nonce string
+		handler := b.SecurityHeadersWith(e.options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonce = CSPNonce(r)
+		}))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		if raw, err := base64.StdEncoding.DecodeString(nonce); err != nil || len(raw) != 16 {
+			t.Errorf("%s: expected a 16 byte nonce, got %q", e.name, nonce)
+		}
+
+		for header, value := range e.expected {
+			value = strings.ReplaceAll(value, "{nonce}", nonce)
+			if got := w.Header().Get(header); got != value {
+				t.Errorf("%s: expected %s to be %q, got %q", e.name, header, value, got)
+			}
+		}
+	}
+}
+
+func TestBendis_SecurityHeaders_Nonce(t *testing.T) {
+	options := SecurityHeadersOptions{ContentSecurityPolicy: "script-src 'nonce-{nonce}'"}
+	b := &Bendis{}
+
+	var nonce string
+	handler := b.SecurityHeadersWith(options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonce(r)
+	}))
+
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if seen[nonce] {
+			t.Fatalf("nonce %s was used twice", nonce)
+		}
+		seen[nonce] = true
+	}
+
+	// a nonce the request already has is kept, so that the page and the header agree
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, render.WithNonce(httptest.NewRequest("GET", "/", nil), "abc"))
+	if nonce != "abc" || w.Header().Get("Content-Security-Policy") != "script-src 'nonce-abc'" {
+		t.Errorf("expected the nonce abc to be kept, got %s and %s", nonce, w.Header().Get("Content-Security-Policy"))
+	}
+}
+
+func TestBendis_SecurityHeadersSettings(t *testing.T) {
+	t.Setenv("HSTS_MAX_AGE", "60")
+	t.Setenv("HSTS_PRELOAD", "true")
+	t.Setenv("FRAME_OPTIONS", "off")
+	t.Setenv("REFERRER_POLICY", "no-referrer")
+	t.Setenv("CONTENT_SECURITY_POLICY", "default-src 'self'")
+
+	options := (&Bendis{}).securityHeadersSettings()
+
+	var tests = []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"hsts max age", options.HSTSMaxAge, 60},
+		{"hsts preload", options.HSTSPreload, true},
+		{"hsts subdomains", options.HSTSIncludeSubdomains, false},
+		{"frame options off", options.FrameOptions, ""},
+		{"referrer policy", options.ReferrerPolicy, "no-referrer"},
+		{"content type options default", options.ContentTypeOptions, "nosniff"},
+		{"csp", options.ContentSecurityPolicy, "default-src 'self'"},
+	}
+
+	for _, e := range tests {
+		if e.value != e.expected {
+			t.Errorf("%s: expected %v, got %v", e.name, e.expected, e.value)
+		}
+	}
+}
@@ -30,6 +30,12 @@ func (b *Bendis) ListenAndServer() error {
 		defer badgerConn.Close()
 	}
 
+	// pick up maintenance set before a restart, or by another instance, before serving anything
+	if b.config.maintenance.store != nil {
+		b.syncMaintenance()
+		go b.watchMaintenance()
+	}
+
 	go b.listenRPC()
 
 	b.InfoLog.Printf("Listening on port %s", os.Getenv("PORT"))
@@ -0,0 +1,60 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/zgoerbe/bendis/cache"
+)
+
+// BadgerStore keeps sessions in a badger database, usually the one the application caches in.
+// Badger removes sessions itself once they expire. Sessions are kept under cache.InternalPrefix, so
+// emptying the cache does not log everyone out.
+type BadgerStore struct {
+	DB     *badger.DB
+	Prefix string // put in front of session tokens, to keep them apart from other keys
+}
+
+// NewBadgerStore returns a store that keeps sessions in db
+func NewBadgerStore(db *badger.DB) *BadgerStore {
+	return &BadgerStore{DB: db, Prefix: cache.InternalPrefix + "session:"}
+}
+
+// Find returns the data of the session token, if it exists and has not expired
+func (s *BadgerStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+	err := s.DB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(s.Prefix + token))
+		if err != nil {
+			return err
+		}
+
+		b, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit saves the data of the session token until expiry
+func (s *BadgerStore) Commit(token string, b []byte, expiry time.Time) error {
+	return s.DB.Update(func(txn *badger.Txn) error {
+		e := badger.NewEntry([]byte(s.Prefix+token), b)
+		e.ExpiresAt = uint64(expiry.Unix())
+		return txn.SetEntry(e)
+	})
+}
+
+// Delete removes the session token
+func (s *BadgerStore) Delete(token string) error {
+	return s.DB.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(s.Prefix + token))
+	})
+}
@@ -0,0 +1,35 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func TestBadgerStore(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testStore(t, "badger", NewBadgerStore(db))
+}
+
+func TestSession_InitBadgerSession(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	c := &Session{
+		CookieLifetime: "100",
+		SessionType:    "badger",
+		BadgerConn:     db,
+	}
+
+	if _, ok := c.InitSession().Store.(*BadgerStore); !ok {
+		t.Error("badger session does not use the badger store")
+	}
+}
@@ -0,0 +1,255 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// maxCookieSize is the most browsers are sure to keep of a cookie, name and attributes included
+const maxCookieSize = 4096
+
+// ErrCookieTooLarge is returned when the session holds more than fits into a cookie
+var ErrCookieTooLarge = errors.New("session data is too large to be kept in a cookie")
+
+// errCookieNotCommitted is returned when the session was committed by another store than the one
+// whose LoadAndSave is in use
+var errCookieNotCommitted = errors.New("session cookie was not committed by this store")
+
+// CookieStore keeps the session in the session cookie itself, encrypted and authenticated with
+// AES-GCM, so sessions survive restarts and are shared by every instance with the same key. As
+// scs stores only ever get a token to put into the cookie, it must be used with its own
+// LoadAndSave middleware rather than the session manager's.
+type CookieStore struct {
+	Key          []byte   // 16, 24 or 32 bytes
+	PreviousKeys [][]byte // keys that were in use before; cookies made with them are still read
+
+	// pending holds the cookie values made by Commit, until LoadAndSave sends them
+	pending sync.Map
+}
+
+// NewCookieStore returns a store for key, or an error if key is not a valid AES key
+func NewCookieStore(key []byte, previousKeys ...[]byte) (*CookieStore, error) {
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, err
+	}
+	return &CookieStore{Key: key, PreviousKeys: previousKeys}, nil
+}
+
+// Find decrypts the session data in token, the value of the cookie. Cookies that were tampered
+// with, made with an unknown key, or have expired are not found.
+func (c *CookieStore) Find(token string) ([]byte, bool, error) {
+	if i := strings.IndexByte(token, '.'); i >= 0 {
+		token = token[i+1:]
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	for _, key := range append([][]byte{c.Key}, c.PreviousKeys...) {
+		data, err := open(key, sealed)
+		if err != nil {
+			continue
+		}
+
+		expiry := time.Unix(int64(binary.BigEndian.Uint64(data[:8])), 0)
+		if time.Now().After(expiry) {
+			return nil, false, nil
+		}
+		return data[8:], true, nil
+	}
+
+	return nil, false, nil
+}
+
+// Commit encrypts b along with its expiry, for LoadAndSave to send as the cookie
+func (c *CookieStore) Commit(token string, b []byte, expiry time.Time) error {
+	data := make([]byte, 8, 8+len(b))
+	binary.BigEndian.PutUint64(data, uint64(expiry.Unix()))
+	data = append(data, b...)
+
+	sealed, err := seal(c.Key, data)
+	if err != nil {
+		return err
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(sealed)
+	if len(value) > maxCookieSize-256 {
+		return ErrCookieTooLarge
+	}
+
+	c.pending.Store(token, value)
+	return nil
+}
+
+// Delete does nothing, as there is nothing kept on the server; LoadAndSave removes the cookie of
+// destroyed sessions
+func (c *CookieStore) Delete(token string) error {
+	return nil
+}
+
+// LoadAndSave is middleware that loads the session from the cookie, and sends the cookie again if
+// the session changed
+func (c *CookieStore) LoadAndSave(s *scs.SessionManager, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		cookie, err := r.Cookie(s.Cookie.Name)
+		if err == nil && cookie.Value != "" {
+			// an id in front of the cookie value keeps the tokens of concurrent requests of the
+			// same session apart, so that each gets back its own cookie from Commit
+			id := make([]byte, 12)
+			if _, err := rand.Read(id); err != nil {
+				s.ErrorFunc(w, r, err)
+				return
+			}
+			token = base64.RawURLEncoding.EncodeToString(id) + "." + cookie.Value
+
+			// a cookie value committed under the token but never sent, e.g. because the handler
+			// committed the session and then destroyed it, is not kept forever
+			defer c.pending.Delete(token)
+		}
+
+		ctx, err := s.Load(r.Context(), token)
+		if err != nil {
+			s.ErrorFunc(w, r, err)
+			return
+		}
+
+		sr := r.WithContext(ctx)
+		bw := &bufferedResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(bw, sr)
+
+		if sr.MultipartForm != nil {
+			_ = sr.MultipartForm.RemoveAll()
+		}
+
+		switch s.Status(ctx) {
+		case scs.Modified:
+			token, expiry, err := s.Commit(ctx)
+			if err != nil {
+				s.ErrorFunc(w, r, err)
+				return
+			}
+
+			value, ok := c.pending.LoadAndDelete(token)
+			if !ok {
+				s.ErrorFunc(w, r, errCookieNotCommitted)
+				return
+			}
+			writeSessionCookie(w, s, value.(string), expiry)
+		case scs.Destroyed:
+			writeSessionCookie(w, s, "", time.Time{})
+		}
+
+		if bw.code != 0 {
+			w.WriteHeader(bw.code)
+		}
+		_, _ = w.Write(bw.buf.Bytes())
+	})
+}
+
+func seal(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("session cookie is too short")
+	}
+
+	data, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 {
+		return nil, errors.New("session cookie has no expiry")
+	}
+	return data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeSessionCookie sends the session cookie, or removes it if value is empty, the way the session
+// manager's own middleware does
+func writeSessionCookie(w http.ResponseWriter, s *scs.SessionManager, value string, expiry time.Time) {
+	cookie := &http.Cookie{
+		Name:     s.Cookie.Name,
+		Value:    value,
+		Path:     s.Cookie.Path,
+		Domain:   s.Cookie.Domain,
+		Secure:   s.Cookie.Secure,
+		HttpOnly: s.Cookie.HttpOnly,
+		SameSite: s.Cookie.SameSite,
+	}
+
+	if expiry.IsZero() {
+		cookie.Expires = time.Unix(1, 0)
+		cookie.MaxAge = -1
+	} else if s.Cookie.Persist {
+		cookie.Expires = time.Unix(expiry.Unix()+1, 0)
+		cookie.MaxAge = int(time.Until(expiry).Seconds() + 1)
+	}
+
+	w.Header().Add("Set-Cookie", cookie.String())
+	w.Header().Add("Cache-Control", `no-cache="Set-Cookie"`)
+	w.Header().Add("Vary", "Cookie")
+}
+
+// bufferedResponseWriter holds the response back until the cookie has been set
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+}
+
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return bw.buf.Write(b)
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(code int) {
+	if !bw.wroteHeader {
+		bw.code = code
+		bw.wroteHeader = true
+	}
+}
+
+func (bw *bufferedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj := bw.ResponseWriter.(http.Hijacker)
+	return hj.Hijack()
+}
@@ -0,0 +1,168 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("01234567890123456789012345678901")
+
+func TestCookieStore_FindCommit(t *testing.T) {
+	store, err := NewCookieStore(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Commit("token", []byte("data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Error(err)
+	}
+
+	value, _ := store.pending.LoadAndDelete("token")
+
+	b, found, err := store.Find("id." + value.(string))
+	if err != nil || !found || string(b) != "data" {
+		t.Error("did not find the committed session data; found:", found, "err:", err)
+	}
+
+	tampered := []byte(value.(string))
+	tampered[len(tampered)-1] ^= 1
+	if _, found, _ := store.Find(string(tampered)); found {
+		t.Error("found session data in a tampered cookie")
+	}
+
+	_ = store.Commit("expired", []byte("data"), time.Now().Add(-time.Minute))
+	value, _ = store.pending.LoadAndDelete("expired")
+	if _, found, _ := store.Find(value.(string)); found {
+		t.Error("found session data in an expired cookie")
+	}
+}
+
+func TestCookieStore_PreviousKeys(t *testing.T) {
+	old, _ := NewCookieStore([]byte("abcdefghijabcdefghijabcdefghij12"))
+	_ = old.Commit("token", []byte("data"), time.Now().Add(time.Hour))
+	value, _ := old.pending.LoadAndDelete("token")
+
+	store, _ := NewCookieStore(testKey)
+	if _, found, _ := store.Find(value.(string)); found {
+		t.Error("found session data made with another key")
+	}
+
+	store.PreviousKeys = [][]byte{old.Key}
+	if _, found, _ := store.Find(value.(string)); !found {
+		t.Error("did not find session data made with a previous key")
+	}
+}
+
+func TestCookieStore_LoadAndSave(t *testing.T) {
+	c := &Session{
+		CookieLifetime: "100",
+		CookieName:     "bendis",
+		SessionType:    "cookie",
+		EncryptionKey:  testKey,
+	}
+	session := c.InitSession()
+
+	store, ok := session.Store.(*CookieStore)
+	if !ok {
+		t.Fatal("cookie session does not use the cookie store")
+	}
+
+	put := store.LoadAndSave(session, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session.Put(r.Context(), "userID", 1)
+	}))
+	get := store.LoadAndSave(session, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if session.GetInt(r.Context(), "userID") != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	put.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "bendis" {
+		t.Fatal("no session cookie sent after putting a value into the session")
+	}
+	if cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Error("session cookie is not SameSite=Lax by default")
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	get.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Error("value put into the session was not in the cookie")
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("session cookie sent again, although the session did not change")
+	}
+}
+
+func TestCookieStore_LoadAndSave_Pending(t *testing.T) {
+	c := &Session{
+		CookieLifetime: "100",
+		CookieName:     "bendis",
+		SessionType:    "cookie",
+		EncryptionKey:  testKey,
+	}
+	session := c.InitSession()
+	store := session.Store.(*CookieStore)
+
+	put := store.LoadAndSave(session, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session.Put(r.Context(), "userID", 1)
+	}))
+	w := httptest.NewRecorder()
+	put.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	cookie := w.Result().Cookies()[0]
+
+	// committed by the handler, but never sent, as the session is destroyed
+	logout := store.LoadAndSave(session, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session.Put(r.Context(), "userID", 2)
+		if _, _, err := session.Commit(r.Context()); err != nil {
+			t.Error(err)
+		}
+		_ = session.Destroy(r.Context())
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	logout.ServeHTTP(w, r)
+
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Value != "" {
+		t.Error("session cookie was not removed")
+	}
+
+	pending := 0
+	store.pending.Range(func(key, value interface{}) bool {
+		pending++
+		return true
+	})
+	if pending != 0 {
+		t.Errorf("expected no pending cookies after the request, got %d", pending)
+	}
+}
+
+func TestSession_IdleTimeout(t *testing.T) {
+	c := &Session{
+		CookieLifetime: "100",
+		IdleTimeout:    "20",
+		CookieSameSite: "strict",
+		SessionType:    "cookie",
+		EncryptionKey:  testKey,
+	}
+
+	session := c.InitSession()
+
+	if session.Lifetime != 100*time.Minute || session.IdleTimeout != 20*time.Minute {
+		t.Error("wrong lifetime or idle timeout; got", session.Lifetime, session.IdleTimeout)
+	}
+	if session.Cookie.SameSite != http.SameSiteStrictMode {
+		t.Error("session cookie is not SameSite=Strict")
+	}
+}
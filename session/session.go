@@ -5,7 +5,9 @@ import (
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/postgresstore"
 	"github.com/alexedwards/scs/redisstore"
+	"github.com/dgraph-io/badger/v3"
 	"github.com/gomodule/redigo/redis"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -21,8 +23,13 @@ type Session struct {
 	CookieDomain   string
 	SessionType    string
 	CookieSecure   string
-	DBPool         *sql.DB
+	CookieSameSite string // strict, lax or none; lax by default
+	IdleTimeout    string // minutes without a request after which the session ends; CookieLifetime is the absolute limit
+	EncryptionKey  []byte // encrypts the cookie of cookie sessions
+	PreviousKeys   [][]byte
+	DBPool         *sql.DB // the database for mysql, postgres and sqlite sessions
 	RedisPool      *redis.Pool
+	BadgerConn     *badger.DB
 }
 
 func (c *Session) InitSession() *scs.SessionManager {
@@ -47,11 +54,14 @@ func (c *Session) InitSession() *scs.SessionManager {
 	// create session
 	session := scs.New()
 	session.Lifetime = time.Duration(minutes) * time.Minute
+	if idle, err := strconv.Atoi(c.IdleTimeout); err == nil && idle > 0 {
+		session.IdleTimeout = time.Duration(idle) * time.Minute
+	}
 	session.Cookie.Persist = persist
 	session.Cookie.Name = c.CookieName
 	session.Cookie.Secure = secure
 	session.Cookie.Domain = c.CookieDomain
-	session.Cookie.SameSite = http.SameSiteLaxMode
+	session.Cookie.SameSite = sameSite(c.CookieSameSite)
 
 	// which session store?
 	switch strings.ToLower(c.SessionType) {
@@ -61,10 +71,39 @@ func (c *Session) InitSession() *scs.SessionManager {
 		session.Store = mysqlstore.New(c.DBPool)
 	case "postgres", "postgresql":
 		session.Store = postgresstore.New(c.DBPool)
+	case "badger":
+		if c.BadgerConn == nil {
+			log.Println("there is no badger database for sessions; keeping sessions in memory")
+			break
+		}
+		session.Store = NewBadgerStore(c.BadgerConn)
+	case "sqlite", "sqlite3":
+		store, err := NewSQLiteStore(c.DBPool)
+		if err != nil {
+			log.Println("could not create the sqlite session store; keeping sessions in memory:", err)
+			break
+		}
+		session.Store = store
 
 	default:
 		// cookie
+		store, err := NewCookieStore(c.EncryptionKey, c.PreviousKeys...)
+		if err != nil {
+			log.Println("cookie sessions need KEY to be 32 characters long; keeping sessions in memory:", err)
+			break
+		}
+		session.Store = store
 	}
 
 	return session
 }
+
+func sameSite(mode string) http.SameSite {
+	switch strings.ToLower(mode) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
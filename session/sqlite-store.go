@@ -0,0 +1,88 @@
+package session
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// SQLiteStore keeps sessions in a sqlite database, for small deployments without a database
+// server. It creates its table, and removes expired sessions every CleanupInterval.
+type SQLiteStore struct {
+	DB              *sql.DB
+	CleanupInterval time.Duration
+	stopCleanup     chan bool
+}
+
+// NewSQLiteStore creates the sessions table in db if it does not exist, and starts removing
+// expired sessions every five minutes
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	_, err := db.Exec(`create table if not exists sessions (
+		token text primary key,
+		data blob not null,
+		expiry integer not null
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("create index if not exists sessions_expiry_idx on sessions (expiry)")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SQLiteStore{DB: db, CleanupInterval: 5 * time.Minute, stopCleanup: make(chan bool)}
+	go s.startCleanup()
+
+	return s, nil
+}
+
+// Find returns the data of the session token, if it exists and has not expired
+func (s *SQLiteStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+	err := s.DB.QueryRow("select data from sessions where token = ? and expiry > ?", token, time.Now().Unix()).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit saves the data of the session token until expiry
+func (s *SQLiteStore) Commit(token string, b []byte, expiry time.Time) error {
+	_, err := s.DB.Exec("replace into sessions (token, data, expiry) values (?, ?, ?)", token, b, expiry.Unix())
+	return err
+}
+
+// Delete removes the session token
+func (s *SQLiteStore) Delete(token string) error {
+	_, err := s.DB.Exec("delete from sessions where token = ?", token)
+	return err
+}
+
+// StopCleanup stops removing expired sessions, e.g. before the database is closed in tests
+func (s *SQLiteStore) StopCleanup() {
+	if s.stopCleanup != nil {
+		s.stopCleanup <- true
+	}
+}
+
+func (s *SQLiteStore) startCleanup() {
+	ticker := time.NewTicker(s.CleanupInterval)
+	for {
+		select {
+		case <-ticker.C:
+			_, err := s.DB.Exec("delete from sessions where expiry <= ?", time.Now().Unix())
+			if err != nil {
+				log.Println(err)
+			}
+		case <-s.stopCleanup:
+			ticker.Stop()
+			return
+		}
+	}
+}
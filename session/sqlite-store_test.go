@@ -0,0 +1,24 @@
+package session
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	db, err := sql.Open("sqlite3", t.TempDir()+"/sessions.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store, err := NewSQLiteStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.StopCleanup()
+
+	testStore(t, "sqlite", store)
+}
@@ -0,0 +1,49 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// testStore commits, finds and deletes a session in store
+func testStore(t *testing.T, name string, store scs.Store) {
+	err := store.Commit("token", []byte("data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Error(name, err)
+	}
+
+	b, found, err := store.Find("token")
+	if err != nil || !found || string(b) != "data" {
+		t.Errorf("%s: did not find the committed session; found: %v, err: %v", name, found, err)
+	}
+
+	err = store.Commit("token", []byte("changed"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Error(name, err)
+	}
+	if b, _, _ := store.Find("token"); string(b) != "changed" {
+		t.Errorf("%s: committing the session again did not overwrite it", name)
+	}
+
+	err = store.Delete("token")
+	if err != nil {
+		t.Error(name, err)
+	}
+	if _, found, _ := store.Find("token"); found {
+		t.Errorf("%s: found the session after deleting it", name)
+	}
+
+	if _, found, err := store.Find("missing"); found || err != nil {
+		t.Errorf("%s: found a session that was never committed; err: %v", name, err)
+	}
+
+	err = store.Commit("expired", []byte("data"), time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Error(name, err)
+	}
+	if _, found, _ := store.Find("expired"); found {
+		t.Errorf("%s: found an expired session", name)
+	}
+}
@@ -0,0 +1,88 @@
+package bendis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zgoerbe/bendis/urlsigner"
+	"net/http"
+	"strings"
+)
+
+type signedURLKey struct{}
+
+// SignedURL is middleware that lets a request through only if its url was signed for purpose with
+// URLSigner, and has not expired or, for single use urls, been used. Tampered links get a 403, and
+// expired or used ones a 410, as a page or as problem+json. The claims are available to handlers
+// with SignedURLClaims.
+func (b *Bendis) SignedURL(purpose string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := b.VerifySignedURL(r, purpose)
+			if err != nil {
+				b.signedURLError(w, r, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signedURLKey{}, claims)))
+		})
+	}
+}
+
+// SignedURLClaims returns the claims of a request that passed the SignedURL middleware
+func SignedURLClaims(r *http.Request) (*urlsigner.Claims, bool) {
+	claims, ok := r.Context().Value(signedURLKey{}).(*urlsigner.Claims)
+	return claims, ok
+}
+
+// VerifySignedURL verifies the full url of r, the application URL followed by the request URI,
+// for purpose. If the SignedURL middleware has verified it already, its claims are returned, so
+// single use urls are not used up twice.
+func (b *Bendis) VerifySignedURL(r *http.Request, purpose string) (*urlsigner.Claims, error) {
+	if claims, ok := SignedURLClaims(r); ok && claims.Purpose == purpose {
+		return claims, nil
+	}
+
+	return b.URLSigner().Verify(fmt.Sprintf("%s%s", b.Server.URL, r.RequestURI), purpose)
+}
+
+// CheckSignedURL checks the full url of r for purpose like VerifySignedURL, without using up single
+// use urls, for pages with a form that posts the link back to be verified
+func (b *Bendis) CheckSignedURL(r *http.Request, purpose string) (*urlsigner.Claims, error) {
+	return b.URLSigner().Check(fmt.Sprintf("%s%s", b.Server.URL, r.RequestURI), purpose)
+}
+
+// VerifySignedPath verifies a signed path and query, such as the request URI of the page a form was
+// on, posted back in a hidden field, for purpose
+func (b *Bendis) VerifySignedPath(signedPath, purpose string) (*urlsigner.Claims, error) {
+	if !strings.HasPrefix(signedPath, "/") {
+		return nil, urlsigner.ErrInvalidSignature
+	}
+	return b.URLSigner().Verify(fmt.Sprintf("%s%s", b.Server.URL, signedPath), purpose)
+}
+
+func (b *Bendis) signedURLError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusForbidden
+	if errors.Is(err, urlsigner.ErrExpired) || errors.Is(err, urlsigner.ErrAlreadyUsed) {
+		status = http.StatusGone
+	} else if !errors.Is(err, urlsigner.ErrInvalidSignature) {
+		// the cache could not be reached, or there is none for a single use url
+		b.ErrorLog.Println(err)
+		status = http.StatusInternalServerError
+	}
+
+	if wantsJSON(r) {
+		detail := err.Error()
+		if status == http.StatusInternalServerError {
+			detail = ""
+		}
+
+		err = b.WriteProblem(w, r, status, detail)
+		if err != nil {
+			b.ErrorLog.Println(err)
+		}
+		return
+	}
+
+	b.ErrorResponse(w, r, status)
+}
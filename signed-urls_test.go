@@ -0,0 +1,144 @@
+package bendis
+
+import (
+	"github.com/zgoerbe/bendis/cache"
+	"github.com/zgoerbe/bendis/urlsigner"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBendis_SignedURL(t *testing.T) {
+	b := &Bendis{
+		EncryptionKey: testKey,
+		ErrorLog:      log.New(io.Discard, "", 0),
+		Server:        Server{URL: "https://example.com"},
+		Cache:         cache.NewMemoryCache(),
+	}
+
+	sign := func(path string, opts urlsigner.Options) string {
+		signed, err := b.URLSigner().Sign(b.Server.URL+path, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimPrefix(signed, b.Server.URL)
+	}
+
+	var purpose string
+	handler := b.SignedURL("download")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := SignedURLClaims(r)
+		if !ok {
+			t.Error("expected the claims in the context")
+			return
+		}
+		purpose = claims.Purpose
+
+		// verifying again in the handler does not use up a single use url
+		if _, err := b.VerifySignedURL(r, "download"); err != nil {
+			t.Errorf("expected the handler to verify the url again, got %s", err)
+		}
+	}))
+
+	once := sign("/files/1", urlsigner.Options{Purpose: "download", TTL: time.Hour, SingleUse: true})
+
+	var tests = []struct {
+		name   string
+		path   string
+		accept string
+		status int
+	}{
+		{"signed", sign("/files/1", urlsigner.Options{Purpose: "download", TTL: time.Hour}), "", http.StatusOK},
+		{"not signed", "/files/1", "", http.StatusForbidden},
+		{"tampered", strings.Replace(sign("/files/1", urlsigner.Options{Purpose: "download"}), "/files/1", "/files/2", 1), "", http.StatusForbidden},
+		{"other purpose", sign("/files/1", urlsigner.Options{Purpose: "password-reset"}), "", http.StatusForbidden},
+		{"expired", sign("/files/1", urlsigner.Options{Purpose: "download", TTL: -time.Minute}), "", http.StatusGone},
+		{"expired, json", sign("/files/1", urlsigner.Options{Purpose: "download", TTL: -time.Minute}), "application/json", http.StatusGone},
+		{"single use", once, "", http.StatusOK},
+		{"used", once, "", http.StatusGone},
+	}
+
+	for _, e := range tests {
+		purpose = ""
+		r := httptest.NewRequest("GET", e.path, nil)
+		r.Header.Set("Accept", e.accept)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, w.Code)
+		}
+		if e.status == http.StatusOK && purpose != "download" {
+			t.Errorf("%s: expected the handler to get the claims", e.name)
+		}
+		if e.status != http.StatusOK && purpose != "" {
+			t.Errorf("%s: expected the handler not to be called", e.name)
+		}
+		if e.accept != "" && w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: expected a problem response, got %s", e.name, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestBendis_SignedURL_NoCache(t *testing.T) {
+	var logged strings.Builder
+	b := &Bendis{
+		EncryptionKey: testKey,
+		ErrorLog:      log.New(&logged, "", 0),
+		Server:        Server{URL: "https://example.com"},
+	}
+
+	signed, err := b.URLSigner().Sign("https://example.com/files/1", urlsigner.Options{Purpose: "download", TTL: time.Hour, SingleUse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := b.SignedURL("download")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected the handler not to be called")
+	}))
+
+	r := httptest.NewRequest("GET", strings.TrimPrefix(signed, "https://example.com"), nil)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 for a single use url without a cache, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), urlsigner.ErrNoCache.Error()) {
+		t.Error("expected the error to be logged rather than sent")
+	}
+	if !strings.Contains(logged.String(), urlsigner.ErrNoCache.Error()) {
+		t.Errorf("expected the error to be logged, got %q", logged.String())
+	}
+}
+
+func TestBendis_VerifySignedPath(t *testing.T) {
+	b := &Bendis{EncryptionKey: testKey, Server: Server{URL: "https://example.com"}}
+
+	signed, err := b.URLSigner().Sign("https://example.com/invite?team=1", urlsigner.Options{Purpose: "invite"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := strings.TrimPrefix(signed, "https://example.com")
+
+	var tests = []struct {
+		name   string
+		path   string
+		failed bool
+	}{
+		{"path", path, false},
+		{"full url", signed, true},
+		{"other host", "//evil.com" + path, true},
+	}
+
+	for _, e := range tests {
+		_, err := b.VerifySignedPath(e.path, "invite")
+		if (err != nil) != e.failed {
+			t.Errorf("%s: expected failed to be %t, got %v", e.name, e.failed, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package bendis
+
+import (
+	"github.com/CloudyKit/jet/v6"
+	"sync"
+)
+
+// templateCache holds parsed jet templates, like jet's own cache, but can be emptied so that
+// changed templates are picked up without a restart
+type templateCache struct {
+	m sync.Map
+}
+
+func (c *templateCache) Get(templatePath string) *jet.Template {
+	t, ok := c.m.Load(templatePath)
+	if !ok {
+		return nil
+	}
+	return t.(*jet.Template)
+}
+
+func (c *templateCache) Put(templatePath string, t *jet.Template) {
+	c.m.Store(templatePath, t)
+}
+
+// clear forgets every parsed template, so that each is parsed again the next time it is used
+func (c *templateCache) clear() {
+	c.m.Range(func(key, _ interface{}) bool {
+		c.m.Delete(key)
+		return true
+	})
+}
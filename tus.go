@@ -0,0 +1,524 @@
+package bendis
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-chi/chi/v5"
+	"github.com/zgoerbe/bendis/filesystems"
+	"github.com/zgoerbe/bendis/scanner"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const tusVersion = "1.0.0"
+
+// sniffLength is the number of bytes mimetype needs to detect a file type
+const sniffLength = 3072
+
+// tusExpiry is how long an upload is kept after the last chunk arrived; abandoned ones are removed
+// when new uploads are created, at most once every tusReapInterval
+const (
+	tusExpiry       = 24 * time.Hour
+	tusReapInterval = time.Hour
+)
+
+// TusUpload describes a resumable upload, and is handed to the completion callback once all bytes have arrived
+type TusUpload struct {
+	ID       string            `json:"id"`
+	Length   int64             `json:"length"`
+	Offset   int64             `json:"offset"`
+	Metadata map[string]string `json:"metadata"`
+	FileName string            `json:"file_name"`
+	Key      string            `json:"key"`
+	Checksum string            `json:"checksum"`
+	MimeType string            `json:"mime_type"`
+	Verified bool              `json:"verified"`
+}
+
+type tusHandler struct {
+	app         *Bendis
+	dir         string
+	destination string
+	fs          filesystems.FS
+	onComplete  func(r *http.Request, upload TusUpload)
+	mu          sync.Mutex
+	locks       map[string]*sync.Mutex
+	reaped      time.Time
+}
+
+// TusUploads returns a handler that implements the core tus 1.0.0 protocol with the creation,
+// expiration and termination extensions, so large files can be uploaded in chunks and resumed after a dropped
+// connection. Mount it below /api so NoSurf lets the PATCH requests through, e.g.
+// app.Routes.Mount("/api/uploads", app.TusUploads("uploads", fs, nil)).
+// Chunks are stored under RootPath/tmp/tus. Once an upload is complete it is checked against
+// ALLOWED_FILETYPES, named according to UPLOAD_NAMING, and handed to fs (or moved into destination
+// when fs is nil), exactly like UploadFile. onComplete, when not nil, is called after the file has
+// been stored. Uploads that have not received a chunk for a day are removed.
+func (b *Bendis) TusUploads(destination string, fs filesystems.FS, onComplete func(r *http.Request, upload TusUpload)) http.Handler {
+	h := &tusHandler{
+		app:         b,
+		dir:         b.RootPath + "/tmp/tus",
+		destination: destination,
+		fs:          fs,
+		onComplete:  onComplete,
+		locks:       make(map[string]*sync.Mutex),
+		reaped:      time.Now().Add(-tusReapInterval),
+	}
+
+	mux := chi.NewRouter()
+	mux.Use(h.checkVersion)
+	mux.Options("/", h.options)
+	mux.Options("/{id}", h.options)
+	mux.Post("/", h.create)
+	mux.Head("/{id}", h.head)
+	mux.Patch("/{id}", h.patch)
+	mux.Delete("/{id}", h.terminate)
+
+	return mux
+}
+
+func (h *tusHandler) checkVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+		if r.Method != http.MethodOptions && r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			http.Error(w, "unsupported tus version", http.StatusPreconditionFailed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *tusHandler) options(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", "creation,expiration,termination")
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.app.uploadPolicy(r).MaxSize, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *tusHandler) create(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+
+	if length > h.app.uploadPolicy(r).MaxSize {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := newTusID()
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	err = os.MkdirAll(h.dir, 0755)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	h.reapLater()
+
+	upload := TusUpload{
+		ID:       id,
+		Length:   length,
+		Metadata: metadata,
+		FileName: sanitizeFileName(metadata["filename"]),
+	}
+
+	f, err := os.Create(h.dataFile(id))
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+	_ = f.Close()
+
+	err = h.saveInfo(upload)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+id)
+	w.Header().Set("Upload-Expires", uploadExpires())
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *tusHandler) head(w http.ResponseWriter, r *http.Request) {
+	upload, err := h.loadInfo(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Upload-Expires", uploadExpires())
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *tusHandler) patch(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if !isTusID(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	lock := h.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	upload, err := h.loadInfo(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset != upload.Offset {
+		http.Error(w, "Upload-Offset does not match", http.StatusConflict)
+		return
+	}
+
+	if r.ContentLength > upload.Length-upload.Offset {
+		http.Error(w, "the chunk goes past Upload-Length", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	f, err := os.OpenFile(h.dataFile(id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	// write what arrives, even if the connection drops part way through, so the client can resume
+	written, copyErr := io.Copy(f, io.LimitReader(r.Body, upload.Length-upload.Offset))
+	_ = f.Close()
+
+	upload.Offset += written
+	err = h.saveInfo(upload)
+	if err != nil {
+		h.serverError(w, err)
+		return
+	}
+
+	if copyErr != nil {
+		// what arrived is kept; the client asks for the offset with HEAD, and resumes from there
+		h.serverError(w, copyErr)
+		return
+	}
+
+	if !upload.Verified && (upload.Offset >= sniffLength || upload.Offset == upload.Length) {
+		err = h.verify(r, &upload)
+		if err != nil {
+			h.remove(id)
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	if upload.Offset == upload.Length {
+		err = h.complete(r, &upload)
+		var infected *scanner.InfectedError
+		if errors.As(err, &infected) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			h.serverError(w, err)
+			return
+		}
+
+		if h.onComplete != nil {
+			h.onComplete(r, upload)
+		}
+	} else {
+		w.Header().Set("Upload-Expires", uploadExpires())
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *tusHandler) terminate(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !isTusID(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	lock := h.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := h.loadInfo(id); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.remove(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verify detects the type of the partial upload, and checks it against the upload policy
+func (h *tusHandler) verify(r *http.Request, upload *TusUpload) error {
+	mimeType, err := mimetype.DetectFile(h.dataFile(upload.ID))
+	if err != nil {
+		return err
+	}
+
+	err = h.app.uploadPolicy(r).checkType(upload.FileName, mimeType)
+	if err != nil {
+		return err
+	}
+
+	upload.MimeType = mimeType.String()
+	upload.Verified = true
+	return h.saveInfo(*upload)
+}
+
+// complete scans the assembled file, names it with the policy's naming strategy, and hands it to
+// the file system. Infected files are removed. If the file cannot be stored, the chunk data is kept,
+// so the client can complete the upload again by sending an empty PATCH at the final offset.
+func (h *tusHandler) complete(r *http.Request, upload *TusUpload) error {
+	scratch := fmt.Sprintf("%s/%s.done", h.dir, upload.ID)
+	defer func() {
+		_ = os.RemoveAll(scratch)
+	}()
+
+	checksum, err := fileChecksum(h.dataFile(upload.ID))
+	if err != nil {
+		return err
+	}
+	upload.Checksum = checksum
+
+	policy := h.app.uploadPolicy(r)
+	err = policy.scan(h.dataFile(upload.ID))
+	if err != nil {
+		var infected *scanner.InfectedError
+		if errors.As(err, &infected) {
+			h.remove(upload.ID)
+		}
+		return err
+	}
+
+	name := sanitizeFileName(policy.Naming(upload.FileName, checksum))
+
+	err = os.MkdirAll(scratch, 0755)
+	if err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("%s/%s", scratch, name)
+	err = os.Rename(h.dataFile(upload.ID), fileName)
+	if err != nil {
+		return err
+	}
+
+	fileName, err = h.app.uniqueName(fileName, h.destination, h.fs, policy.ReuseNames)
+	if err == nil {
+		err = h.app.storeFile(fileName, h.destination, h.fs)
+	}
+	if err != nil {
+		// put the data back for another try
+		if renameErr := os.Rename(fileName, h.dataFile(upload.ID)); renameErr != nil {
+			h.app.ErrorLog.Println(renameErr)
+			h.remove(upload.ID)
+		}
+		return err
+	}
+
+	upload.Key = path.Join(h.destination, path.Base(fileName))
+	h.remove(upload.ID)
+	return nil
+}
+
+// reapLater removes abandoned uploads in the background, if that has not been done for
+// tusReapInterval
+func (h *tusHandler) reapLater() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.reaped) < tusReapInterval {
+		return
+	}
+	h.reaped = time.Now()
+
+	go h.reap()
+}
+
+// reap removes uploads that have not received a chunk for tusExpiry
+func (h *tusHandler) reap() {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		h.app.ErrorLog.Println(err)
+		return
+	}
+
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".info")
+		if !isTusID(id) || entry.Name() != id+".info" {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < tusExpiry {
+			continue
+		}
+
+		lock := h.lock(id)
+		lock.Lock()
+		h.remove(id)
+		lock.Unlock()
+	}
+}
+
+// uploadExpires is the Upload-Expires header of an upload that just received a chunk
+func uploadExpires() string {
+	return time.Now().Add(tusExpiry).UTC().Format(http.TimeFormat)
+}
+
+func (h *tusHandler) remove(id string) {
+	_ = os.Remove(h.dataFile(id))
+	_ = os.Remove(h.infoFile(id))
+	h.unlock(id)
+}
+
+func (h *tusHandler) lock(id string) *sync.Mutex {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	l, ok := h.locks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		h.locks[id] = l
+	}
+	return l
+}
+
+func (h *tusHandler) unlock(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.locks, id)
+}
+
+func (h *tusHandler) dataFile(id string) string {
+	return fmt.Sprintf("%s/%s.bin", h.dir, id)
+}
+
+func (h *tusHandler) infoFile(id string) string {
+	return fmt.Sprintf("%s/%s.info", h.dir, id)
+}
+
+func (h *tusHandler) loadInfo(id string) (TusUpload, error) {
+	var upload TusUpload
+
+	if !isTusID(id) {
+		return upload, errors.New("invalid upload id")
+	}
+
+	data, err := os.ReadFile(h.infoFile(id))
+	if err != nil {
+		return upload, err
+	}
+
+	err = json.Unmarshal(data, &upload)
+	return upload, err
+}
+
+func (h *tusHandler) saveInfo(upload TusUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.infoFile(upload.ID), data, 0644)
+}
+
+func (h *tusHandler) serverError(w http.ResponseWriter, err error) {
+	h.app.ErrorLog.Println(err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// parseTusMetadata parses an Upload-Metadata header: comma separated pairs of a key and a base64 encoded value
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		switch len(parts) {
+		case 1:
+			metadata[parts[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, errors.New("invalid Upload-Metadata")
+			}
+			metadata[parts[0]] = string(value)
+		default:
+			return nil, errors.New("invalid Upload-Metadata")
+		}
+	}
+
+	return metadata, nil
+}
+
+func newTusID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isTusID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// fileChecksum returns the hex encoded sha256 checksum of a file
+func fileChecksum(fileName string) (string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,253 @@
+package bendis
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/zgoerbe/bendis/filesystems"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingFS refuses to store anything, until it is told to
+type failingFS struct {
+	filesystems.FS
+	fail bool
+	put  []string
+}
+
+func (f *failingFS) Put(fileName, folder string) error {
+	if f.fail {
+		return errors.New("storage is down")
+	}
+	f.put = append(f.put, folder+"/"+fileName[strings.LastIndex(fileName, "/")+1:])
+	return nil
+}
+
+func (f *failingFS) List(prefix string) ([]filesystems.Listing, error) {
+	var listing []filesystems.Listing
+	for _, key := range f.put {
+		if strings.HasPrefix(key, prefix+"/") {
+			listing = append(listing, filesystems.Listing{Key: key})
+		}
+	}
+	return listing, nil
+}
+
+func newTusTestApp(t *testing.T) *Bendis {
+	root := t.TempDir()
+	if err := os.MkdirAll(root+"/uploads", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Bendis{
+		RootPath: root,
+		InfoLog:  log.New(io.Discard, "", 0),
+		ErrorLog: log.New(io.Discard, "", 0),
+		config: config{uploads: uploadConfig{
+			allowedMimeTypes: []string{"text/plain; charset=utf-8"},
+			maxUploadSize:    1 << 20,
+			naming:           NameOriginal,
+		}},
+	}
+}
+
+func tusRequest(t *testing.T, handler http.Handler, method, target string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Tus-Resumable", tusVersion)
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func createTusUpload(t *testing.T, handler http.Handler, length string) string {
+	w := tusRequest(t, handler, "POST", "/", map[string]string{
+		"Upload-Length":   length,
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("notes.txt")),
+	}, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 on create, got %d", w.Code)
+	}
+	if w.Header().Get("Upload-Expires") == "" {
+		t.Error("no Upload-Expires on create")
+	}
+	return w.Header().Get("Location")
+}
+
+func patchTus(t *testing.T, handler http.Handler, location, offset, body string) *httptest.ResponseRecorder {
+	return tusRequest(t, handler, "PATCH", location, map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": offset,
+	}, body)
+}
+
+func TestTusUploads(t *testing.T) {
+	b := newTusTestApp(t)
+
+	var completed TusUpload
+	handler := b.TusUploads(b.RootPath+"/uploads", nil, func(r *http.Request, upload TusUpload) {
+		completed = upload
+	})
+
+	if w := tusRequest(t, handler, "POST", "/", map[string]string{"Tus-Resumable": "0.2.0", "Upload-Length": "10"}, ""); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for an unsupported version, got %d", w.Code)
+	}
+
+	if w := tusRequest(t, handler, "POST", "/", map[string]string{"Upload-Length": "2000000"}, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for an upload over the size limit, got %d", w.Code)
+	}
+
+	location := createTusUpload(t, handler, "11")
+
+	w := tusRequest(t, handler, "HEAD", location, nil, "")
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "0" || w.Header().Get("Upload-Length") != "11" {
+		t.Errorf("unexpected HEAD of a new upload: %d, offset %s, length %s", w.Code, w.Header().Get("Upload-Offset"), w.Header().Get("Upload-Length"))
+	}
+
+	w = patchTus(t, handler, location, "0", "hello")
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "5" {
+		t.Errorf("unexpected first PATCH: %d, offset %s", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	if w = patchTus(t, handler, location, "0", "hello"); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a wrong offset, got %d", w.Code)
+	}
+
+	if w = patchTus(t, handler, location, "5", " world and more"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a chunk past the length, got %d", w.Code)
+	}
+
+	w = tusRequest(t, handler, "HEAD", location, nil, "")
+	if w.Header().Get("Upload-Offset") != "5" {
+		t.Errorf("expected offset 5 after the refused chunks, got %s", w.Header().Get("Upload-Offset"))
+	}
+
+	if w = patchTus(t, handler, location, "5", " world"); w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected last PATCH: %d %s", w.Code, w.Body.String())
+	}
+
+	data, err := os.ReadFile(b.RootPath + "/uploads/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("unexpected upload content %q", data)
+	}
+
+	if completed.Key != b.RootPath+"/uploads/notes.txt" || completed.Checksum == "" {
+		t.Errorf("unexpected completed upload: %+v", completed)
+	}
+
+	if w = tusRequest(t, handler, "HEAD", location, nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a completed upload, got %d", w.Code)
+	}
+}
+
+func TestTusUploads_StoreFailure(t *testing.T) {
+	b := newTusTestApp(t)
+	fs := &failingFS{fail: true}
+	handler := b.TusUploads("uploads", fs, nil)
+
+	location := createTusUpload(t, handler, "5")
+
+	if w := patchTus(t, handler, location, "0", "hello"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when the file cannot be stored, got %d", w.Code)
+	}
+
+	w := tusRequest(t, handler, "HEAD", location, nil, "")
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "5" {
+		t.Fatalf("upload was not kept after the store failed: %d, offset %s", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	fs.fail = false
+	if w = patchTus(t, handler, location, "5", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected the upload to complete on retry, got %d", w.Code)
+	}
+
+	if len(fs.put) != 1 || fs.put[0] != "uploads/notes.txt" {
+		t.Errorf("unexpected stored files: %v", fs.put)
+	}
+}
+
+// brokenBody returns its data, and then fails the way a dropped connection does
+type brokenBody struct {
+	data io.Reader
+}
+
+func (b brokenBody) Read(p []byte) (int, error) {
+	n, err := b.data.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestTusUploads_BrokenChunk(t *testing.T) {
+	b := newTusTestApp(t)
+	var logged strings.Builder
+	b.ErrorLog = log.New(&logged, "", 0)
+	handler := b.TusUploads(b.RootPath+"/uploads", nil, nil)
+
+	location := createTusUpload(t, handler, "11")
+
+	r := httptest.NewRequest("PATCH", location, brokenBody{strings.NewReader("hello")})
+	r.Header.Set("Tus-Resumable", tusVersion)
+	r.Header.Set("Content-Type", "application/offset+octet-stream")
+	r.Header.Set("Upload-Offset", "0")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 for a chunk that broke off, got %d", w.Code)
+	}
+	if !strings.Contains(logged.String(), io.ErrUnexpectedEOF.Error()) {
+		t.Errorf("expected the error to be logged, got %q", logged.String())
+	}
+
+	w = tusRequest(t, handler, "HEAD", location, nil, "")
+	if w.Header().Get("Upload-Offset") != "5" {
+		t.Errorf("expected the bytes that arrived to be kept, got offset %s", w.Header().Get("Upload-Offset"))
+	}
+
+	if w = patchTus(t, handler, location, "5", " world"); w.Code != http.StatusNoContent {
+		t.Errorf("expected the upload to resume, got %d", w.Code)
+	}
+}
+
+func TestTusUploads_Reap(t *testing.T) {
+	b := newTusTestApp(t)
+	handler := b.TusUploads("uploads", nil, nil)
+
+	abandoned := createTusUpload(t, handler, "5")
+	active := createTusUpload(t, handler, "5")
+
+	id := abandoned[strings.LastIndex(abandoned, "/")+1:]
+	old := time.Now().Add(-2 * tusExpiry)
+	if err := os.Chtimes(b.RootPath+"/tmp/tus/"+id+".info", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &tusHandler{app: b, dir: b.RootPath + "/tmp/tus", locks: make(map[string]*sync.Mutex)}
+	h.reap()
+
+	if w := tusRequest(t, handler, "HEAD", abandoned, nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected the abandoned upload to be removed, got %d", w.Code)
+	}
+	if _, err := os.Stat(b.RootPath + "/tmp/tus/" + id + ".bin"); !os.IsNotExist(err) {
+		t.Error("the data of the abandoned upload was kept")
+	}
+
+	if w := tusRequest(t, handler, "HEAD", active, nil, ""); w.Code != http.StatusOK {
+		t.Errorf("expected the active upload to be kept, got %d", w.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package bendis
+
+import (
+	"crypto/rand"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// NamingStrategy decides the name an uploaded file is stored under, given the client supplied
+// file name and the hex encoded sha256 checksum of its contents
+type NamingStrategy func(original, checksum string) string
+
+// NameOriginal keeps the client supplied name, stripped of directories and unsafe characters
+func NameOriginal(original, checksum string) string {
+	return sanitizeFileName(original)
+}
+
+// NameUUID stores the file under a random UUID, keeping the extension
+func NameUUID(original, checksum string) string {
+	u := make([]byte, 16)
+	_, _ = rand.Read(u)
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x%s", u[0:4], u[4:6], u[6:8], u[8:10], u[10:], fileExtension(original))
+}
+
+// NameHash stores the file under its sha256 checksum, keeping the extension, so identical
+// uploads end up with the same key when the policy sets ReuseNames
+func NameHash(original, checksum string) string {
+	return checksum + fileExtension(original)
+}
+
+// NameSlugTimestamp stores the file as a slug of the original name followed by a timestamp,
+// e.g. my-holiday-photo-20220131154500.jpg
+func NameSlugTimestamp(original, checksum string) string {
+	ext := fileExtension(original)
+	base := strings.TrimSuffix(path.Base(strings.ReplaceAll(original, "\\", "/")), path.Ext(original))
+
+	return fmt.Sprintf("%s-%s%s", slugify(base), time.Now().Format("20060102150405"), ext)
+}
+
+// namingStrategy returns the strategy for the UPLOAD_NAMING setting (original, uuid, hash or slug)
+func namingStrategy(name string) NamingStrategy {
+	switch strings.ToLower(name) {
+	case "uuid":
+		return NameUUID
+	case "hash":
+		return NameHash
+	case "slug":
+		return NameSlugTimestamp
+	default:
+		return NameOriginal
+	}
+}
+
+// fileExtension returns the lower cased, sanitized extension of a file name, including the dot
+func fileExtension(name string) string {
+	ext := strings.ToLower(path.Ext(sanitizeFileName(name)))
+	if ext == "." {
+		return ""
+	}
+	return ext
+}
+
+// sanitizeFileName strips any directories from a client supplied file name, and replaces
+// everything but letters, digits, dots, dashes and underscores
+func sanitizeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	clean := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+
+	clean = strings.TrimLeft(clean, ".")
+	if clean == "" {
+		clean = "upload"
+	}
+
+	return clean
+}
+
+// slugify lower cases s, and joins runs of letters and digits with single dashes
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	if b.Len() == 0 {
+		return "upload"
+	}
+	return b.String()
+}
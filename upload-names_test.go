@@ -0,0 +1,60 @@
+package bendis
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	var tests = []struct {
+		name     string
+		original string
+		expected string
+	}{
+		{"plain", "photo.jpg", "photo.jpg"},
+		{"directories", "../../etc/passwd", "passwd"},
+		{"windows directories", `C:\Users\me\photo.jpg`, "photo.jpg"},
+		{"spaces and symbols", "my photo (1).jpg", "my_photo__1_.jpg"},
+		{"unicode", "fotó.png", "fot_.png"},
+		{"hidden file", ".htaccess", "htaccess"},
+		{"dots only", "..", "upload"},
+		{"empty", "", "upload"},
+		{"trailing slash", "photos/", "photos"},
+	}
+
+	for _, e := range tests {
+		if name := sanitizeFileName(e.original); name != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, name)
+		}
+	}
+}
+
+func TestNamingStrategies(t *testing.T) {
+	checksum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	var tests = []struct {
+		name     string
+		strategy NamingStrategy
+		original string
+		expected string
+	}{
+		{"original", namingStrategy("original"), "../My Photo.JPG", `^My_Photo\.JPG$`},
+		{"unknown setting", namingStrategy(""), "photo.jpg", `^photo\.jpg$`},
+		{"uuid", namingStrategy("uuid"), "photo.JPG", `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\.jpg$`},
+		{"uuid without extension", namingStrategy("UUID"), "photo", `^[0-9a-f-]{36}$`},
+		{"hash", namingStrategy("hash"), "photo.png", `^` + checksum + `\.png$`},
+		{"slug", namingStrategy("slug"), "My Holiday Photo!.jpg", `^my-holiday-photo-\d{14}\.jpg$`},
+		{"slug of nothing", namingStrategy("slug"), "!!!.gif", `^upload-\d{14}\.gif$`},
+	}
+
+	for _, e := range tests {
+		name := e.strategy(e.original, checksum)
+		if !regexp.MustCompile(e.expected).MatchString(name) {
+			t.Errorf("%s: expected a name matching %s, got %s", e.name, e.expected, name)
+		}
+	}
+
+	if NameUUID("a.txt", checksum) == NameUUID("a.txt", checksum) {
+		t.Error("expected different uuids for the same file")
+	}
+}
@@ -0,0 +1,143 @@
+package bendis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/zgoerbe/bendis/scanner"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type uploadPolicyKey struct{}
+
+// UploadPolicy overrides the global upload settings (ALLOWED_FILETYPES, MAX_UPLOAD_SIZE,
+// UPLOAD_NAMING and the configured scanner) for a route or a single call. Zero values fall
+// back to the global settings.
+type UploadPolicy struct {
+	AllowedMimeTypes []string
+	MaxSize          int64
+	MaxCount         int
+	CheckExtension   bool
+	Naming           NamingStrategy
+	ReuseNames       bool // store files whose name is taken under that name, rather than numbering them; for NameHash
+	Scanner          scanner.Scanner
+}
+
+// WithUploadPolicy returns a copy of r that carries policy, for use with a single call to
+// UploadFile, UploadFiles or UploadImage
+func WithUploadPolicy(r *http.Request, policy UploadPolicy) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), uploadPolicyKey{}, policy))
+}
+
+// UploadPolicy is middleware that applies policy to every upload in a route group
+func (b *Bendis) UploadPolicy(policy UploadPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, WithUploadPolicy(r, policy))
+		})
+	}
+}
+
+// uploadFormOverhead is the room left for the other form fields and the multipart headers when the
+// size of an upload request is limited
+const uploadFormOverhead = 1 << 20
+
+// limitBody caps the body of r at count files of the policy's size, so that a request cannot fill
+// the disk before the size of each file is checked. ParseMultipartForm only keeps its argument in
+// memory, and writes the rest of the body to temporary files.
+func (policy UploadPolicy) limitBody(r *http.Request, count int) {
+	if count < 1 {
+		count = 1
+	}
+	r.Body = http.MaxBytesReader(nil, r.Body, policy.MaxSize*int64(count)+uploadFormOverhead)
+}
+
+// uploadPolicy returns the policy for r, with every unset value filled in from the global config
+func (b *Bendis) uploadPolicy(r *http.Request) UploadPolicy {
+	policy, _ := r.Context().Value(uploadPolicyKey{}).(UploadPolicy)
+
+	if policy.AllowedMimeTypes == nil {
+		policy.AllowedMimeTypes = b.config.uploads.allowedMimeTypes
+	}
+
+	if policy.MaxSize == 0 {
+		policy.MaxSize = b.config.uploads.maxUploadSize
+	}
+
+	if policy.Naming == nil {
+		policy.Naming = b.config.uploads.naming
+		policy.ReuseNames = policy.ReuseNames || b.config.uploads.reuseNames
+	}
+	if policy.Naming == nil {
+		policy.Naming = NameOriginal
+	}
+
+	if policy.Scanner == nil {
+		policy.Scanner = b.config.uploads.scanner
+	}
+
+	return policy
+}
+
+// checkType returns an error if the detected type is not allowed, or if it does not match the
+// extension of the file name and the policy asks for that
+func (p UploadPolicy) checkType(fileName string, detected *mimetype.MIME) error {
+	if !inSlice(p.AllowedMimeTypes, detected.String()) {
+		return errors.New("invalid type uploaded")
+	}
+
+	if p.CheckExtension && !extensionMatches(fileName, detected) {
+		return fmt.Errorf("file extension does not match its type %s", detected.String())
+	}
+
+	return nil
+}
+
+// scan runs the policy's scanner, if any, over the file
+func (p UploadPolicy) scan(fileName string) error {
+	if p.Scanner == nil {
+		return nil
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return p.Scanner.Scan(f)
+}
+
+// extensionMatches reports whether the extension of fileName belongs to the detected type, or to
+// one of the types it is a special case of (e.g. .txt for text/csv)
+func extensionMatches(fileName string, detected *mimetype.MIME) bool {
+	ext := fileExtension(fileName)
+	if ext == "" {
+		return false
+	}
+
+	byExtension, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext))
+
+	for m := detected; m != nil; m = m.Parent() {
+		if m.Extension() == ext || (byExtension != "" && m.Is(byExtension)) {
+			return true
+		}
+	}
+
+	// a few common aliases that neither mimetype nor the mime package know about
+	aliases := map[string][]string{
+		"image/jpeg": {".jpeg", ".jpe"},
+		"image/tiff": {".tif"},
+	}
+	for _, alias := range aliases[strings.Split(detected.String(), ";")[0]] {
+		if alias == ext {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,83 @@
+package bendis
+
+import (
+	"github.com/gabriel-vasile/mimetype"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtensionMatches(t *testing.T) {
+	var tests = []struct {
+		name     string
+		fileName string
+		mimeType string
+		expected bool
+	}{
+		{"png", "a.png", "image/png", true},
+		{"upper case", "A.PNG", "image/png", true},
+		{"jpeg alias", "a.jpeg", "image/jpeg", true},
+		{"jpe alias", "a.jpe", "image/jpeg", true},
+		{"jpg", "a.jpg", "image/jpeg", true},
+		{"tif alias", "a.tif", "image/tiff", true},
+		{"pdf", "a.pdf", "application/pdf", true},
+		{"csv as text", "a.txt", "text/csv", true},
+		{"mismatch", "a.png", "image/jpeg", false},
+		{"executable as image", "a.jpg", "application/vnd.microsoft.portable-executable", false},
+		{"no extension", "photo", "image/png", false},
+	}
+
+	for _, e := range tests {
+		detected := mimetype.Lookup(e.mimeType)
+		if detected == nil {
+			t.Fatalf("%s: unknown mime type %s", e.name, e.mimeType)
+		}
+
+		if matches := extensionMatches(e.fileName, detected); matches != e.expected {
+			t.Errorf("%s: expected %t, got %t", e.name, e.expected, matches)
+		}
+	}
+}
+
+func TestUploadPolicy_CheckType(t *testing.T) {
+	policy := UploadPolicy{AllowedMimeTypes: []string{"image/png"}, CheckExtension: true}
+
+	var tests = []struct {
+		name     string
+		fileName string
+		mimeType string
+		valid    bool
+	}{
+		{"allowed", "a.png", "image/png", true},
+		{"not allowed", "a.jpg", "image/jpeg", false},
+		{"wrong extension", "a.jpg", "image/png", false},
+	}
+
+	for _, e := range tests {
+		err := policy.checkType(e.fileName, mimetype.Lookup(e.mimeType))
+		if (err == nil) != e.valid {
+			t.Errorf("%s: expected valid to be %t, got %v", e.name, e.valid, err)
+		}
+	}
+}
+
+func TestBendis_UploadPolicy_ReuseNames(t *testing.T) {
+	b := &Bendis{config: config{uploads: uploadConfig{naming: NameHash, reuseNames: true}}}
+
+	var tests = []struct {
+		name     string
+		policy   UploadPolicy
+		expected bool
+	}{
+		{"global hash naming", UploadPolicy{}, true},
+		{"naming of its own", UploadPolicy{Naming: NameUUID}, false},
+		{"hash naming of its own", UploadPolicy{Naming: NameHash}, false},
+		{"reused names of its own", UploadPolicy{Naming: NameHash, ReuseNames: true}, true},
+	}
+
+	for _, e := range tests {
+		policy := b.uploadPolicy(WithUploadPolicy(httptest.NewRequest("POST", "/", nil), e.policy))
+		if policy.ReuseNames != e.expected {
+			t.Errorf("%s: expected ReuseNames to be %t, got %t", e.name, e.expected, policy.ReuseNames)
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package bendis
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/zgoerbe/bendis/filesystems"
+	"github.com/zgoerbe/bendis/images"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path"
+	"sort"
+	"strings"
 )
 
 func (b *Bendis) UploadFile(r *http.Request, destination, field string, fs filesystems.FS) error {
@@ -18,30 +24,221 @@ func (b *Bendis) UploadFile(r *http.Request, destination, field string, fs files
 		return err
 	}
 
-	if fs != nil {
-		err = fs.Put(fileName, destination)
+	// delete temp file after upload
+	defer removeTempUpload(fileName)
+
+	fileName, err = b.uniqueName(fileName, destination, fs, b.uploadPolicy(r).ReuseNames)
+	if err != nil {
+		b.ErrorLog.Println(err)
+		return err
+	}
+
+	err = b.storeFile(fileName, destination, fs)
+	if err != nil {
+		b.ErrorLog.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// UploadImage uploads an image like UploadFile, and also generates the given variants (thumbnails,
+// resized or re-encoded copies without EXIF data) and stores them next to the original. The
+// original is stored without its EXIF data too. It returns the stored keys, with the original under
+// "original" and every variant under its name.
+func (b *Bendis) UploadImage(r *http.Request, destination, field string, fs filesystems.FS, variants ...images.Variant) (map[string]string, error) {
+	fileName, err := b.getFileToUpload(r, field)
+	if err != nil {
+		b.ErrorLog.Println(err)
+		return nil, err
+	}
+
+	// the variants are written next to the temp file, and removed with it
+	defer removeTempUpload(fileName)
+
+	// rename the original first, so that the names of the variants follow it
+	fileName, err = b.uniqueName(fileName, destination, fs, b.uploadPolicy(r).ReuseNames)
+	if err != nil {
+		b.ErrorLog.Println(err)
+		return nil, err
+	}
+
+	files, err := images.Process(fileName, path.Dir(fileName), variants...)
+	if err != nil {
+		b.ErrorLog.Println(err)
+		return nil, err
+	}
+
+	// the variants are made first, as they are rotated by the EXIF data
+	err = images.StripMetadata(fileName)
+	if err != nil {
+		b.ErrorLog.Println(err)
+		return nil, err
+	}
+
+	keys := make(map[string]string)
+	files["original"] = fileName
+
+	for name, f := range files {
+		err = b.storeFile(f, destination, fs)
 		if err != nil {
 			b.ErrorLog.Println(err)
-			return err
+			return keys, err
 		}
-	} else {
-		err = os.Rename(fileName, fmt.Sprintf("%s/%s", destination, path.Base(fileName)))
-		if err != nil {
-			b.ErrorLog.Println(err)
-			return err
+		keys[name] = path.Join(destination, path.Base(f))
+	}
+
+	return keys, nil
+}
+
+// storeFile puts a local file on fs, or moves it into the destination folder if fs is nil
+func (b *Bendis) storeFile(fileName, destination string, fs filesystems.FS) error {
+	if fs != nil {
+		return fs.Put(fileName, destination)
+	}
+
+	return os.Rename(fileName, fmt.Sprintf("%s/%s", destination, path.Base(fileName)))
+}
+
+// uniqueName renames a received file if a file of the same name is already in destination, by
+// adding a number to its name, e.g. photo-2.jpg, and returns its new path. With reuse, the file
+// is left alone and replaces the stored one. On error, the file keeps its path.
+func (b *Bendis) uniqueName(fileName, destination string, fs filesystems.FS, reuse bool) (string, error) {
+	if reuse {
+		return fileName, nil
+	}
+
+	taken, err := b.storedNames(destination, fs)
+	if err != nil {
+		return fileName, err
+	}
+
+	name := path.Base(fileName)
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := name
+	for i := 2; taken(candidate); i++ {
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+
+	if candidate == name {
+		return fileName, nil
+	}
+
+	renamed := path.Join(path.Dir(fileName), candidate)
+	err = os.Rename(fileName, renamed)
+	if err != nil {
+		return fileName, err
+	}
+
+	return renamed, nil
+}
+
+// storedNames returns a function that reports whether a file name is in use in destination
+func (b *Bendis) storedNames(destination string, fs filesystems.FS) (func(string) bool, error) {
+	if fs == nil {
+		return func(name string) bool {
+			_, err := os.Stat(path.Join(destination, name))
+			return err == nil
+		}, nil
+	}
+
+	listing, err := fs.List(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	// depending on the file system, keys are either full paths or names within the folder
+	folder := strings.Trim(destination, "/")
+	names := make(map[string]bool)
+	for _, item := range listing {
+		name := strings.Trim(item.Key, "/")
+		if folder != "" {
+			name = strings.TrimPrefix(name, folder+"/")
+		}
+		if !strings.Contains(name, "/") {
+			names[name] = true
 		}
 	}
 
-	// delete temp file after upload
-	defer func() {
-		_ = os.Remove(fileName)
-	}()
+	return func(name string) bool { return names[name] }, nil
+}
 
-	return nil
+// UploadedFile describes the outcome of uploading a single file
+type UploadedFile struct {
+	Field        string
+	OriginalName string
+	Key          string
+	Size         int64
+	MimeType     string
+	Checksum     string
+	Error        error
+}
+
+// UploadFiles uploads every file in the given form fields (or in all fields, if none are given),
+// including fields that hold several files, and stores them in destination on fs (or locally,
+// if fs is nil). It returns one result per file; a file that could not be uploaded has its Error
+// set, and does not stop the others. The returned error is only set if the form could not be read.
+func (b *Bendis) UploadFiles(r *http.Request, destination string, fs filesystems.FS, fields ...string) ([]UploadedFile, error) {
+	policy := b.uploadPolicy(r)
+	policy.limitBody(r, policy.MaxCount)
+
+	err := r.ParseMultipartForm(policy.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 0 {
+		for field := range r.MultipartForm.File {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+	}
+
+	if policy.MaxCount > 0 {
+		count := 0
+		for _, field := range fields {
+			count += len(r.MultipartForm.File[field])
+		}
+		if count > policy.MaxCount {
+			return nil, fmt.Errorf("too many files uploaded: at most %d are allowed", policy.MaxCount)
+		}
+	}
+
+	var results []UploadedFile
+	for _, field := range fields {
+		for _, header := range r.MultipartForm.File[field] {
+			result, fileName, err := b.receiveFile(field, header, policy)
+			if err == nil {
+				fileName, err = b.uniqueName(fileName, destination, fs, policy.ReuseNames)
+				if err == nil {
+					result.Key = path.Base(fileName)
+					err = b.storeFile(fileName, destination, fs)
+				}
+				removeTempUpload(fileName)
+			}
+
+			if err != nil {
+				b.ErrorLog.Println(err)
+				result.Key = ""
+				result.Error = err
+			} else {
+				result.Key = path.Join(destination, result.Key)
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
 }
 
 func (b *Bendis) getFileToUpload(r *http.Request, fieldName string) (string, error) {
-	err := r.ParseMultipartForm(b.config.uploads.maxUploadSize)
+	policy := b.uploadPolicy(r)
+	policy.limitBody(r, 1)
+
+	err := r.ParseMultipartForm(policy.MaxSize)
 	if err != nil {
 		return "", err
 	}
@@ -50,36 +247,107 @@ func (b *Bendis) getFileToUpload(r *http.Request, fieldName string) (string, err
 	if err != nil {
 		return "", err
 	}
+	_ = file.Close()
+
+	_, fileName, err := b.receiveFile(fieldName, header, policy)
+	if err != nil {
+		return "", err
+	}
+
+	return fileName, nil
+}
+
+// receiveFile checks the type and size of an uploaded file against the policy, copies it into a
+// folder of its own below RootPath/tmp, named according to the policy's naming strategy, and
+// scans it. It returns the details of the file and the path of the temporary copy, which must be
+// removed with removeTempUpload. On error, nothing is left behind.
+func (b *Bendis) receiveFile(field string, header *multipart.FileHeader, policy UploadPolicy) (UploadedFile, string, error) {
+	result := UploadedFile{
+		Field:        field,
+		OriginalName: header.Filename,
+		Size:         header.Size,
+	}
+
+	if header.Size > policy.MaxSize {
+		return result, "", errors.New("uploaded file is too big")
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return result, "", err
+	}
 	defer file.Close()
 
 	// detect the file
 	mimeType, err := mimetype.DetectReader(file)
 	if err != nil {
-		return "", err
+		return result, "", err
 	}
+	result.MimeType = mimeType.String()
 
 	// go back to start of file
 	_, err = file.Seek(0, 0)
 	if err != nil {
-		return "", err
+		return result, "", err
 	}
 
-	if !inSlice(b.config.uploads.allowedMimeTypes, mimeType.String()) {
-		return "", errors.New("invalid type uploaded")
+	err = policy.checkType(header.Filename, mimeType)
+	if err != nil {
+		return result, "", err
 	}
 
-	dst, err := os.Create(fmt.Sprintf("./tmp/%s", header.Filename))
+	dir, err := os.MkdirTemp(b.RootPath+"/tmp", "upload-")
 	if err != nil {
-		return "", err
+		return result, "", err
 	}
-	defer dst.Close()
 
-	_, err = io.Copy(dst, file)
+	partial := dir + "/upload.part"
+	hash := sha256.New()
+	result.Size, err = copyToFile(partial, io.MultiWriter(hash), file)
 	if err != nil {
-		return "", err
+		_ = os.RemoveAll(dir)
+		return result, "", err
 	}
+	result.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	// scan before the file gets anywhere near a file system
+	err = policy.scan(partial)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return result, "", err
+	}
+
+	result.Key = sanitizeFileName(policy.Naming(header.Filename, result.Checksum))
+
+	fileName := fmt.Sprintf("%s/%s", dir, result.Key)
+	err = os.Rename(partial, fileName)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return result, "", err
+	}
+
+	return result, fileName, nil
+}
+
+// copyToFile copies src into a new file, and into every extra writer (e.g. a hash)
+func copyToFile(fileName string, extra io.Writer, src io.Reader) (int64, error) {
+	dst, err := os.Create(fileName)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(io.MultiWriter(dst, extra), src)
+	if err != nil {
+		_ = dst.Close()
+		return n, err
+	}
+
+	return n, dst.Close()
+}
 
-	return fmt.Sprintf("./tmp/%s", header.Filename), nil
+// removeTempUpload removes the temporary folder created for an upload by receiveFile
+func removeTempUpload(fileName string) {
+	_ = os.RemoveAll(path.Dir(fileName))
 }
 
 func inSlice(slice []string, value string) bool {
@@ -0,0 +1,240 @@
+package bendis
+
+import (
+	"bytes"
+	"github.com/zgoerbe/bendis/images"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// uploadRequest builds a multipart request with one file per name in the field "files"
+func uploadRequest(t *testing.T, names ...string) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, name := range names {
+		part, err := w.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = part.Write([]byte("some notes about " + name))
+	}
+	_ = w.Close()
+
+	r := httptest.NewRequest("POST", "/", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	return r
+}
+
+func newUploadTestApp(t *testing.T) *Bendis {
+	b := newTusTestApp(t)
+	if err := os.MkdirAll(b.RootPath+"/tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBendis_UploadFiles(t *testing.T) {
+	b := newUploadTestApp(t)
+	destination := b.RootPath + "/uploads"
+
+	results, err := b.UploadFiles(uploadRequest(t, "notes.txt", "../notes.txt", "other notes.txt"), destination, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"notes.txt", "notes-2.txt", "other_notes.txt"}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+
+	for i, e := range expected {
+		if results[i].Error != nil {
+			t.Errorf("%s: %s", e, results[i].Error)
+			continue
+		}
+		if results[i].Key != destination+"/"+e {
+			t.Errorf("expected key %s, got %s", destination+"/"+e, results[i].Key)
+		}
+		if results[i].MimeType != "text/plain; charset=utf-8" || results[i].Checksum == "" {
+			t.Errorf("%s: missing details %+v", e, results[i])
+		}
+		if _, err := os.Stat(results[i].Key); err != nil {
+			t.Errorf("%s was not stored: %s", e, err)
+		}
+	}
+
+	// a name taken by an earlier request
+	results, _ = b.UploadFiles(uploadRequest(t, "notes.txt"), destination, nil)
+	if len(results) != 1 || results[0].Key != destination+"/notes-3.txt" {
+		t.Errorf("expected notes-3.txt, got %+v", results)
+	}
+}
+
+func TestBendis_UploadFiles_FileSystem(t *testing.T) {
+	b := newUploadTestApp(t)
+	fs := &failingFS{}
+
+	var tests = []struct {
+		name     string
+		policy   UploadPolicy
+		expected string
+	}{
+		{"first", UploadPolicy{}, "uploads/notes.txt"},
+		{"taken", UploadPolicy{}, "uploads/notes-2.txt"},
+		{"taken twice", UploadPolicy{}, "uploads/notes-3.txt"},
+		{"hash names are reused", UploadPolicy{Naming: NameHash, ReuseNames: true}, ""},
+	}
+
+	var hashKey string
+	for _, e := range tests {
+		results, err := b.UploadFiles(WithUploadPolicy(uploadRequest(t, "notes.txt"), e.policy), "uploads", fs)
+		if err != nil || len(results) != 1 || results[0].Error != nil {
+			t.Fatalf("%s: unexpected results %+v, %v", e.name, results, err)
+		}
+
+		if e.expected != "" && results[0].Key != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, results[0].Key)
+		}
+		hashKey = results[0].Key
+	}
+
+	results, _ := b.UploadFiles(WithUploadPolicy(uploadRequest(t, "notes.txt"), UploadPolicy{Naming: NameHash, ReuseNames: true}), "uploads", fs)
+	if results[0].Key != hashKey {
+		t.Errorf("expected the hash name %s again, got %s", hashKey, results[0].Key)
+	}
+}
+
+func TestBendis_UploadFiles_Policy(t *testing.T) {
+	b := newUploadTestApp(t)
+	destination := b.RootPath + "/uploads"
+
+	var tests = []struct {
+		name   string
+		policy UploadPolicy
+		files  []string
+		failed bool
+		errors int
+	}{
+		{"too big", UploadPolicy{MaxSize: 10}, []string{"notes.txt"}, false, 1},
+		{"type not allowed", UploadPolicy{AllowedMimeTypes: []string{"image/png"}}, []string{"notes.txt"}, false, 1},
+		{"extension does not match", UploadPolicy{CheckExtension: true}, []string{"notes.png", "notes.txt"}, false, 1},
+		{"too many", UploadPolicy{MaxCount: 1}, []string{"a.txt", "b.txt"}, true, 0},
+	}
+
+	for _, e := range tests {
+		results, err := b.UploadFiles(WithUploadPolicy(uploadRequest(t, e.files...), e.policy), destination, nil)
+		if (err != nil) != e.failed {
+			t.Errorf("%s: expected failed to be %t, got %v", e.name, e.failed, err)
+			continue
+		}
+
+		errors := 0
+		for _, result := range results {
+			if result.Error != nil {
+				errors++
+				if result.Key != "" {
+					t.Errorf("%s: a failed upload has the key %s", e.name, result.Key)
+				}
+			}
+		}
+		if errors != e.errors {
+			t.Errorf("%s: expected %d failed files, got %d", e.name, e.errors, errors)
+		}
+	}
+}
+
+func TestBendis_UploadFiles_BodyTooLarge(t *testing.T) {
+	b := newUploadTestApp(t)
+	destination := b.RootPath + "/uploads"
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("files", "big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write(bytes.Repeat([]byte("a"), 2*uploadFormOverhead))
+	_ = w.Close()
+
+	policy := UploadPolicy{MaxSize: 1024, MaxCount: 1}
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest("POST", "/", bytes.NewReader(body.Bytes()))
+		r.Header.Set("Content-Type", w.FormDataContentType())
+		return WithUploadPolicy(r, policy)
+	}
+
+	if _, err := b.UploadFiles(newRequest(), destination, nil); err == nil {
+		t.Error("UploadFiles: expected the body to be refused")
+	}
+
+	if err := b.UploadFile(newRequest(), destination, "files", nil); err == nil {
+		t.Error("UploadFile: expected the body to be refused")
+	}
+
+	if _, err := os.Stat(destination + "/big.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be stored, got %v", err)
+	}
+}
+
+func TestBendis_UploadImage(t *testing.T) {
+	b := newUploadTestApp(t)
+	destination := b.RootPath + "/uploads"
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 40, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+	// a comment segment right after the start of the image, which is metadata like EXIF is
+	data := append([]byte{0xff, 0xd8, 0xff, 0xfe, 0, 8}, "secret"...)
+	data = append(data, photo.Bytes()[2:]...)
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("photo", "photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write(data)
+	_ = w.Close()
+
+	r := httptest.NewRequest("POST", "/", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	r = WithUploadPolicy(r, UploadPolicy{AllowedMimeTypes: []string{"image/jpeg"}})
+
+	keys, err := b.UploadImage(r, destination, "photo", nil, images.Thumbnail("thumb", 16), images.Variant{Name: "modern", Width: 20, Format: "webp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		expected string
+	}{
+		{"original", destination + "/photo.jpg"},
+		{"thumb", destination + "/photo-thumb.jpg"},
+		{"modern", destination + "/photo-modern.webp"},
+	}
+
+	for _, e := range tests {
+		if keys[e.name] != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, keys[e.name])
+			continue
+		}
+		stored, err := os.ReadFile(e.expected)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+		if bytes.Contains(stored, []byte("secret")) {
+			t.Errorf("%s: the metadata was stored", e.name)
+		}
+	}
+}
@@ -1,16 +1,223 @@
 package urlsigner
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	goalone "github.com/bwmarrin/go-alone"
+	"github.com/zgoerbe/bendis/cache"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("signed url has expired")
+	ErrAlreadyUsed      = errors.New("signed url has already been used")
+	ErrNoCache          = errors.New("single use urls need a cache that can count")
+	ErrNoTTL            = errors.New("single use urls must expire")
+)
+
 type Signer struct {
-	Secret []byte
+	Secret          []byte
+	PreviousSecrets [][]byte    // secrets that were in use before, still accepted when verifying
+	Cache           cache.Cache // remembers single use urls that have been used; it must be a cache.Counter
+}
+
+// Options control what Sign puts into a signed url
+type Options struct {
+	TTL       time.Duration // how long the url is valid; zero means it does not expire
+	Purpose   string        // what the url is for, e.g. password-reset; it must be given again to verify
+	SingleUse bool          // whether the url may be used only once; it needs a TTL, so used urls are not remembered forever
+}
+
+// Claims are what a verified url was signed with
+type Claims struct {
+	Expires   time.Time // zero if the url does not expire
+	Purpose   string
+	SingleUse bool
+	Nonce     string
+}
+
+// Sign adds expires, nonce and signature parameters to rawURL. The signature covers the url, its
+// expiry and the purpose, so none of them can be changed without invalidating it.
+func (s *Signer) Sign(rawURL string, opts Options) (string, error) {
+	if opts.SingleUse && opts.TTL <= 0 {
+		return "", ErrNoTTL
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	if opts.TTL != 0 {
+		params.Set("expires", strconv.FormatInt(time.Now().Add(opts.TTL).Unix(), 10))
+	}
+	params.Set("nonce", hex.EncodeToString(nonce))
+	if opts.SingleUse {
+		params.Set("single", "1")
+	}
+
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+	unsigned := rawURL + separator + params.Encode()
+
+	return unsigned + "&signature=" + sign(s.Secret, opts.Purpose, unsigned), nil
 }
 
+// Verify checks the signature of a url made by Sign for purpose, and that it has not expired. Single
+// use urls are marked as used, so verifying them a second time fails with ErrAlreadyUsed.
+func (s *Signer) Verify(signedURL, purpose string) (*Claims, error) {
+	claims, err := s.verify(signedURL, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.SingleUse {
+		err := s.use(claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
+
+// Check verifies a url like Verify does, but does not use up single use urls. A page with a form,
+// such as the password reset form, checks the link it was opened with, and the handler the form is
+// posted to verifies it.
+func (s *Signer) Check(signedURL, purpose string) (*Claims, error) {
+	claims, err := s.verify(signedURL, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.SingleUse {
+		counter, err := s.counter()
+		if err != nil {
+			return nil, err
+		}
+
+		used, err := counter.Count(usedKey(claims))
+		if err != nil {
+			return nil, err
+		}
+		if used > 0 {
+			return nil, ErrAlreadyUsed
+		}
+	}
+
+	return claims, nil
+}
+
+// verify checks the signature and the expiry of signedURL
+func (s *Signer) verify(signedURL, purpose string) (*Claims, error) {
+	i := strings.LastIndex(signedURL, "&signature=")
+	if i < 0 {
+		return nil, ErrInvalidSignature
+	}
+	unsigned, signature := signedURL[:i], signedURL[i+len("&signature="):]
+
+	if !s.validSignature(purpose, unsigned, signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	u, err := url.Parse(unsigned)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	query := u.Query()
+
+	claims := &Claims{
+		Purpose:   purpose,
+		SingleUse: query.Get("single") == "1",
+		Nonce:     query.Get("nonce"),
+	}
+
+	if expires := query.Get("expires"); expires != "" {
+		unix, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidSignature
+		}
+		claims.Expires = time.Unix(unix, 0)
+
+		if time.Now().After(claims.Expires) {
+			return nil, ErrExpired
+		}
+	}
+
+	if claims.SingleUse && claims.Expires.IsZero() {
+		return nil, ErrInvalidSignature
+	}
+
+	return claims, nil
+}
+
+// use marks a single use url as used, remembering it until it expires. The counter is incremented
+// atomically, so of two requests with the same url at the same time only one gets through.
+func (s *Signer) use(claims *Claims) error {
+	counter, err := s.counter()
+	if err != nil {
+		return err
+	}
+
+	// keep it a moment longer than the url is valid, so it cannot slip through at the very end
+	ttl := int(time.Until(claims.Expires).Seconds()) + 60
+	used, err := counter.Increment(usedKey(claims), ttl)
+	if err != nil {
+		return err
+	}
+	if used > 1 {
+		return ErrAlreadyUsed
+	}
+	return nil
+}
+
+func (s *Signer) counter() (cache.Counter, error) {
+	counter, ok := s.Cache.(cache.Counter)
+	if !ok {
+		return nil, ErrNoCache
+	}
+	return counter, nil
+}
+
+func usedKey(claims *Claims) string {
+	return fmt.Sprintf("urlsigner-used-%s", claims.Nonce)
+}
+
+// validSignature checks signature against the current secret, and then the previous ones
+func (s *Signer) validSignature(purpose, unsigned, signature string) bool {
+	secrets := append([][]byte{s.Secret}, s.PreviousSecrets...)
+	for _, secret := range secrets {
+		if hmac.Equal([]byte(sign(secret, purpose, unsigned)), []byte(signature)) {
+			return true
+		}
+	}
+	return false
+}
+
+func sign(secret []byte, purpose, unsigned string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateTokenFromString signs a url with a timestamp, to be checked with VerifyToken and Expired.
+//
+// Deprecated: use Sign, which puts the expiry into the signature, and supports purposes and single
+// use urls.
 func (s *Signer) GenerateTokenFromString(data string) string {
 	var urlToSign string
 
@@ -31,6 +238,9 @@ func (s *Signer) GenerateTokenFromString(data string) string {
 	return string(crypt.Sign([]byte(urlToSign)))
 }
 
+// VerifyToken checks a url signed with GenerateTokenFromString.
+//
+// Deprecated: use Verify.
 func (s *Signer) VerifyToken(token string) bool {
 	crypt := goalone.New(s.Secret, goalone.Timestamp)
 	_, err := crypt.Unsign([]byte(token))
@@ -40,10 +250,13 @@ func (s *Signer) VerifyToken(token string) bool {
 	return true
 }
 
+// Expired checks the timestamp of a url signed with GenerateTokenFromString.
+//
+// Deprecated: use Sign with a TTL, and Verify.
 func (s *Signer) Expired(token string, minutesUntilExpire int) bool {
 	crypt := goalone.New(s.Secret, goalone.Timestamp)
 
 	ts := crypt.Parse([]byte(token))
 
 	return time.Since(ts.Timestamp) > time.Duration(minutesUntilExpire)*time.Minute
-}
\ No newline at end of file
+}
@@ -0,0 +1,110 @@
+package urlsigner
+
+import (
+	"errors"
+	"github.com/zgoerbe/bendis/cache"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSigner_SignVerify(t *testing.T) {
+	s := Signer{Secret: []byte("secret")}
+
+	signed, err := s.Sign("http://localhost/reset?email=me@here.com", Options{TTL: time.Hour, Purpose: "reset"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := s.Verify(signed, "reset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Expires.IsZero() || claims.SingleUse {
+		t.Error("wrong claims:", claims)
+	}
+
+	if _, err := s.Verify(signed, "login"); !errors.Is(err, ErrInvalidSignature) {
+		t.Error("url verified for another purpose:", err)
+	}
+
+	tampered := strings.Replace(signed, "me@here.com", "you@here.com", 1)
+	if _, err := s.Verify(tampered, "reset"); !errors.Is(err, ErrInvalidSignature) {
+		t.Error("tampered url verified:", err)
+	}
+}
+
+func TestSigner_Expired(t *testing.T) {
+	s := Signer{Secret: []byte("secret")}
+
+	signed, _ := s.Sign("http://localhost/reset", Options{TTL: -time.Minute})
+	if _, err := s.Verify(signed, ""); !errors.Is(err, ErrExpired) {
+		t.Error("expected ErrExpired, got", err)
+	}
+}
+
+func TestSigner_SingleUse(t *testing.T) {
+	s := Signer{Secret: []byte("secret"), Cache: cache.NewMemoryCache()}
+
+	signed, _ := s.Sign("http://localhost/reset", Options{TTL: time.Hour, SingleUse: true})
+	for i := 0; i < 2; i++ {
+		if _, err := s.Check(signed, ""); err != nil {
+			t.Fatal("check used up the url:", err)
+		}
+	}
+	if _, err := s.Verify(signed, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(signed, ""); !errors.Is(err, ErrAlreadyUsed) {
+		t.Error("expected ErrAlreadyUsed, got", err)
+	}
+	if _, err := s.Check(signed, ""); !errors.Is(err, ErrAlreadyUsed) {
+		t.Error("expected ErrAlreadyUsed from check, got", err)
+	}
+
+	if _, err := s.Sign("http://localhost/reset", Options{SingleUse: true}); !errors.Is(err, ErrNoTTL) {
+		t.Error("expected ErrNoTTL, got", err)
+	}
+
+	s.Cache = nil
+	signed, _ = s.Sign("http://localhost/reset", Options{TTL: time.Hour, SingleUse: true})
+	if _, err := s.Verify(signed, ""); !errors.Is(err, ErrNoCache) {
+		t.Error("expected ErrNoCache, got", err)
+	}
+}
+
+func TestSigner_SingleUseConcurrent(t *testing.T) {
+	s := Signer{Secret: []byte("secret"), Cache: cache.NewMemoryCache()}
+	signed, _ := s.Sign("http://localhost/reset", Options{TTL: time.Hour, SingleUse: true})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	verified := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Verify(signed, ""); err == nil {
+				mu.Lock()
+				verified++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if verified != 1 {
+		t.Errorf("expected the url to be used once, but it was used %d times", verified)
+	}
+}
+
+func TestSigner_PreviousSecrets(t *testing.T) {
+	old := Signer{Secret: []byte("old")}
+	signed, _ := old.Sign("http://localhost/download", Options{})
+
+	s := Signer{Secret: []byte("new"), PreviousSecrets: [][]byte{[]byte("old")}}
+	if _, err := s.Verify(signed, ""); err != nil {
+		t.Error("url signed with a previous secret did not verify:", err)
+	}
+}
@@ -2,8 +2,11 @@ package bendis
 
 import (
 	"fmt"
+	"os"
+	"path"
 	"regexp"
 	"runtime"
+	"strings"
 	"time"
 )
 
@@ -16,3 +19,29 @@ func (b *Bendis) LoadTime(start time.Time) {
 
 	b.InfoLog.Println(fmt.Sprintf("Load Time: %s took %s", name, elapsed))
 }
+
+// envList returns the comma separated values of the environment variable key, trimmed, leaving out
+// empty ones
+func envList(key string) []string {
+	var list []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// matchPath reports whether urlPath matches one of globs. A glob ending in /* also matches
+// everything below it, so /api/* covers /api/users/1.
+func matchPath(globs []string, urlPath string) bool {
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, urlPath); ok {
+			return true
+		}
+		if strings.HasSuffix(glob, "/*") && strings.HasPrefix(urlPath, strings.TrimSuffix(glob, "*")) {
+			return true
+		}
+	}
+	return false
+}
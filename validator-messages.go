@@ -0,0 +1,245 @@
+package bendis
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Messages maps message keys to message templates. Keys are rule names, e.g. "required", or a rule
+// name and the kind of value it failed on, e.g. "min.string". In a template, {field} is replaced
+// with the field's label, and {param} with the rule's parameter; rules with a range, such as
+// between, also have {min} and {max}.
+type Messages map[string]string
+
+// messageCatalog holds the messages for every locale. English is the fallback for keys a locale
+// does not translate.
+var messageCatalog = map[string]Messages{
+	"en": {
+		"field":       "This field",
+		"required":    "{field} cannot be blank",
+		"invalid":     "{field} has an invalid value",
+		"email":       "invalid email address",
+		"int":         "{field} must be an integer",
+		"float":       "{field} must be a floating point number",
+		"dateiso":     "{field} must be a date in the form of YYYY-MM-DD",
+		"nospaces":    "Spaces are not permitted",
+		"min":         "{field} must be at least {param}",
+		"max":         "{field} must be at most {param}",
+		"min.string":  "{field} must be at least {param} characters long",
+		"max.string":  "{field} must be at most {param} characters long",
+		"min.slice":   "Select at least {param}",
+		"max.slice":   "Select at most {param}",
+		"minlen":      "{field} must be at least {param} characters long",
+		"maxlen":      "{field} must be at most {param} characters long",
+		"between":     "{field} must be between {min} and {max}",
+		"regex":       "{field} has an invalid format",
+		"in":          "{field} must be one of {param}",
+		"notin":       "This value is not allowed",
+		"url":         "{field} must be a valid URL",
+		"uuid":        "{field} must be a valid UUID",
+		"after":       "{field} must be a date after {param}",
+		"before":      "{field} must be a date before {param}",
+		"afterequal":  "{field} must be a date on or after {param}",
+		"beforeequal": "{field} must be a date on or before {param}",
+		"password":    "The password must be at least {param} characters long, and contain upper and lower case letters, a digit and a symbol",
+		"confirmed":   "The confirmation does not match",
+		"unique":      "This value is already taken",
+		"exists":      "The selected value does not exist",
+		"param.today": "today",
+	},
+	"de": {
+		"field":       "Dieses Feld",
+		"required":    "{field} darf nicht leer sein",
+		"invalid":     "{field} hat einen ungültigen Wert",
+		"email":       "ungültige E-Mail-Adresse",
+		"int":         "{field} muss eine ganze Zahl sein",
+		"float":       "{field} muss eine Zahl sein",
+		"dateiso":     "{field} muss ein Datum im Format JJJJ-MM-TT sein",
+		"nospaces":    "Leerzeichen sind nicht erlaubt",
+		"min":         "{field} muss mindestens {param} sein",
+		"max":         "{field} darf höchstens {param} sein",
+		"min.string":  "{field} muss mindestens {param} Zeichen lang sein",
+		"max.string":  "{field} darf höchstens {param} Zeichen lang sein",
+		"min.slice":   "Wählen Sie mindestens {param} aus",
+		"max.slice":   "Wählen Sie höchstens {param} aus",
+		"minlen":      "{field} muss mindestens {param} Zeichen lang sein",
+		"maxlen":      "{field} darf höchstens {param} Zeichen lang sein",
+		"between":     "{field} muss zwischen {min} und {max} liegen",
+		"regex":       "{field} hat ein ungültiges Format",
+		"in":          "{field} muss einer dieser Werte sein: {param}",
+		"notin":       "Dieser Wert ist nicht erlaubt",
+		"url":         "{field} muss eine gültige URL sein",
+		"uuid":        "{field} muss eine gültige UUID sein",
+		"after":       "{field} muss ein Datum nach {param} sein",
+		"before":      "{field} muss ein Datum vor {param} sein",
+		"afterequal":  "{field} muss ein Datum am oder nach {param} sein",
+		"beforeequal": "{field} muss ein Datum am oder vor {param} sein",
+		"password":    "Das Passwort muss mindestens {param} Zeichen lang sein und Groß- und Kleinbuchstaben, eine Ziffer und ein Sonderzeichen enthalten",
+		"confirmed":   "Die Bestätigung stimmt nicht überein",
+		"unique":      "Dieser Wert ist bereits vergeben",
+		"exists":      "Der ausgewählte Wert existiert nicht",
+		"param.today": "heute",
+	},
+}
+
+// RegisterMessages adds translations for a locale, e.g. "fr" or "pt-BR", replacing any existing
+// messages with the same keys
+func RegisterMessages(locale string, messages Messages) {
+	locale = localeChain(locale)[0]
+	if messageCatalog[locale] == nil {
+		messageCatalog[locale] = Messages{}
+	}
+
+	for key, message := range messages {
+		messageCatalog[locale][key] = message
+	}
+}
+
+// ForRequest selects the locale for messages from the request's Accept-Language header, keeping
+// the current locale when none of the languages asked for has messages
+func (v *Validation) ForRequest(r *http.Request) *Validation {
+	if locale := RequestLocale(r); locale != "" {
+		v.Locale = locale
+	}
+	return v
+}
+
+// SetLabel sets the name field is called by in messages, e.g. "Email address"
+func (v *Validation) SetLabel(field, label string) *Validation {
+	if v.Labels == nil {
+		v.Labels = make(map[string]string)
+	}
+	v.Labels[field] = label
+	return v
+}
+
+// SetMessage overrides the message for a rule. Use the rule name, e.g. "required", to override it
+// for every field, or the field's key and the rule name, e.g. "email.required", for one field.
+func (v *Validation) SetMessage(key, message string) *Validation {
+	if v.Messages == nil {
+		v.Messages = make(Messages)
+	}
+	v.Messages[key] = message
+	return v
+}
+
+// RequestLocale returns the locale with messages that best matches the request's Accept-Language
+// header, or an empty string if there is none
+func RequestLocale(r *http.Request) string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+
+	var languages []language
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if q, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+
+		if quality > 0 {
+			languages = append(languages, language{tag, quality})
+		}
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+
+	for _, l := range languages {
+		for _, locale := range localeChain(l.tag) {
+			if _, ok := messageCatalog[locale]; ok {
+				return locale
+			}
+		}
+	}
+
+	return ""
+}
+
+// localeChain returns a locale followed by its base language, e.g. pt-br and pt
+func localeChain(locale string) []string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if i := strings.Index(locale, "-"); i > 0 {
+		return []string{locale, locale[:i]}
+	}
+	return []string{locale}
+}
+
+// message builds the message for a failed rule on field. kind, if not empty, selects a message
+// specific to the kind of value, e.g. min.string. Overrides on the Validation come first, then the
+// messages for its locale and base language, then English.
+func (v *Validation) message(field, rule, kind string, params map[string]string) string {
+	keys := []string{rule}
+	if kind != "" {
+		keys = []string{rule + "." + kind, rule}
+	}
+
+	template, found := v.Messages[field+"."+rule]
+	for _, key := range keys {
+		if found {
+			break
+		}
+		template, found = v.Messages[key]
+	}
+	if !found {
+		template, found = v.lookup(keys...)
+	}
+	if !found {
+		template = rule
+	}
+
+	label, ok := v.Labels[field]
+	if !ok {
+		label, _ = v.lookup("field")
+	}
+
+	replacements := []string{"{field}", label}
+	for name, value := range params {
+		if translated, ok := v.lookup("param." + value); ok {
+			value = translated
+		}
+		replacements = append(replacements, "{"+name+"}", value)
+	}
+
+	return strings.NewReplacer(replacements...).Replace(template)
+}
+
+// lookup finds the first of keys in the catalog, trying the locale, its base language and English
+func (v *Validation) lookup(keys ...string) (string, bool) {
+	locales := append(localeChain(v.Locale), "en")
+
+	for _, locale := range locales {
+		for _, key := range keys {
+			if message, ok := messageCatalog[locale][key]; ok {
+				return message, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// ruleParams turns a tag rule's parameter into message parameters: {param}, with lists shown
+// comma separated, and {min} and {max} for ranges such as 1:10
+func ruleParams(param string) map[string]string {
+	params := map[string]string{"param": strings.ReplaceAll(param, "|", ", ")}
+
+	if parts := strings.SplitN(param, ":", 2); len(parts) == 2 {
+		params["min"], params["max"] = parts[0], parts[1]
+	}
+
+	return params
+}
@@ -0,0 +1,122 @@
+package bendis
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRequestLocale(t *testing.T) {
+	RegisterMessages("pt_BR", Messages{"required": "{field} é obrigatório"})
+	defer delete(messageCatalog, "pt-br")
+
+	var tests = []struct {
+		name           string
+		acceptLanguage string
+		expected       string
+	}{
+		{"none", "", ""},
+		{"english", "en", "en"},
+		{"region falls back to the language", "de-AT", "de"},
+		{"case and region", "PT-br", "pt-br"},
+		{"unknown", "fr, it", ""},
+		{"first known", "fr, de;q=0.8", "de"},
+		{"by quality", "en;q=0.5, de;q=0.9", "de"},
+		{"refused", "de;q=0, en;q=0.1", "en"},
+		{"wildcard", "*", ""},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if e.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", e.acceptLanguage)
+		}
+
+		if locale := RequestLocale(r); locale != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, locale)
+		}
+	}
+}
+
+func TestValidation_Messages(t *testing.T) {
+	RegisterMessages("xx", Messages{"required": "{field} xx"})
+	defer delete(messageCatalog, "xx")
+
+	var tests = []struct {
+		name     string
+		locale   string
+		labels   map[string]string
+		messages Messages
+		tag      string
+		value    interface{}
+		expected string
+	}{
+		{"english", "en", nil, nil, "required", "", "This field cannot be blank"},
+		{"german", "de", nil, nil, "required", "", "Dieses Feld darf nicht leer sein"},
+		{"german region", "de-CH", nil, nil, "required", "", "Dieses Feld darf nicht leer sein"},
+		{"unknown locale", "fr", nil, nil, "required", "", "This field cannot be blank"},
+		{"missing key falls back to english", "xx", nil, nil, "email", "x", "invalid email address"},
+		{"missing field name falls back to english", "xx", nil, nil, "required", "", "This field xx"},
+		{"label", "de", map[string]string{"name": "Name"}, nil, "required", "", "Name darf nicht leer sein"},
+		{"kind", "en", nil, nil, "min=3", "ab", "This field must be at least 3 characters long"},
+		{"kind of number", "en", nil, nil, "min=3", 2, "This field must be at least 3"},
+		{"kind of slice", "de", nil, nil, "min=2", []string{"a"}, "Wählen Sie mindestens 2 aus"},
+		{"range", "en", nil, nil, "between=1:10", "0", "This field must be between 1 and 10"},
+		{"list", "en", nil, nil, "in=red|green", "blue", "This field must be one of red, green"},
+		{"translated param", "de", nil, nil, "after=today", "2000-01-01", "Dieses Feld muss ein Datum nach heute sein"},
+		{"default param", "en", nil, nil, "password", "x", "The password must be at least 8 characters long, and contain upper and lower case letters, a digit and a symbol"},
+		{"override for the rule", "de", nil, Messages{"required": "Pflicht"}, "required", "", "Pflicht"},
+		{"override for the kind", "en", nil, Messages{"min.string": "short"}, "min=3", "ab", "short"},
+		{"override for the field", "en", nil, Messages{"required": "rule", "name.required": "field"}, "required", "", "field"},
+	}
+
+	for _, e := range tests {
+		v := &Validation{Errors: make(map[string]string), Locale: e.locale, Labels: e.labels, Messages: e.messages}
+		v.validateField(Field{Key: "name", Value: reflect.ValueOf(e.value), Validation: v}, e.tag)
+
+		if v.Errors["name"] != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, v.Errors["name"])
+		}
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	RegisterRule("even", func(f Field, param string) bool {
+		n, ok := measure(f.Value)
+		return ok && int(n)%2 == 0
+	})
+	RegisterRule("prefix", func(f Field, param string) bool {
+		return strings.HasPrefix(stringValue(f.Value), param)
+	}, "app_")
+	RegisterMessages("en", Messages{"even": "{field} must be even", "prefix": "{field} must start with {param}"})
+	defer func() {
+		delete(fieldRules, "even")
+		delete(fieldRules, "prefix")
+		delete(ruleDefaults, "prefix")
+		delete(messageCatalog["en"], "even")
+		delete(messageCatalog["en"], "prefix")
+	}()
+
+	var tests = []struct {
+		name     string
+		tag      string
+		value    interface{}
+		expected string
+	}{
+		{"even", "even", 4, ""},
+		{"odd", "even", 3, "This field must be even"},
+		{"default param", "prefix", "app_name", ""},
+		{"default param fails", "prefix", "name", "This field must start with app_"},
+		{"param given", "prefix=my_", "app_name", "This field must start with my_"},
+	}
+
+	for _, e := range tests {
+		v := &Validation{Errors: make(map[string]string)}
+		v.validateField(Field{Key: "name", Value: reflect.ValueOf(e.value), Validation: v}, e.tag)
+
+		if v.Errors["name"] != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, v.Errors["name"])
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package bendis
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/asaskevich/govalidator"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// identifierRegexp matches table and column names that are safe to put into a query
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Rules with more than one parameter separate them with colons, e.g. between=1:10 or
+// unique=users:email, and lists use pipes, e.g. in=red|green|blue, since commas separate rules.
+// For the same reason, regex patterns in tags cannot contain commas; use Matches for those.
+// unique takes the struct field holding the id of the row being edited as a third parameter,
+// e.g. unique=users:email:ID, so that saving a row does not fail on its own value.
+func init() {
+	RegisterRule("minlen", func(f Field, param string) bool {
+		n, err := strconv.Atoi(param)
+		return err == nil && len([]rune(stringValue(f.Value))) >= n
+	})
+	RegisterRule("maxlen", func(f Field, param string) bool {
+		n, err := strconv.Atoi(param)
+		return err == nil && len([]rune(stringValue(f.Value))) <= n
+	})
+	RegisterRule("between", func(f Field, param string) bool {
+		min, max, err := parseRange(param)
+		if err != nil {
+			return false
+		}
+		n, err := strconv.ParseFloat(stringValue(f.Value), 64)
+		return err == nil && n >= min && n <= max
+	})
+	RegisterRule("regex", func(f Field, param string) bool {
+		re, err := regexp.Compile(param)
+		return err == nil && re.MatchString(stringValue(f.Value))
+	})
+	RegisterRule("in", func(f Field, param string) bool {
+		return inSlice(strings.Split(param, "|"), stringValue(f.Value))
+	})
+	RegisterRule("notin", func(f Field, param string) bool {
+		return !inSlice(strings.Split(param, "|"), stringValue(f.Value))
+	})
+	RegisterRule("url", stringRule(isURL))
+	RegisterRule("uuid", stringRule(govalidator.IsUUID))
+	RegisterRule("after", func(f Field, param string) bool {
+		d, limit, ok := parseDates(f.Value, param)
+		return ok && d.After(limit)
+	})
+	RegisterRule("before", func(f Field, param string) bool {
+		d, limit, ok := parseDates(f.Value, param)
+		return ok && d.Before(limit)
+	})
+	RegisterRule("password", func(f Field, param string) bool {
+		n, err := strconv.Atoi(param)
+		return err == nil && isStrongPassword(stringValue(f.Value), n)
+	}, "8")
+	RegisterRule("confirmed", ruleConfirmed)
+	RegisterRule("unique", func(f Field, param string) bool {
+		table, column, idField := splitTableColumn(param, f.Key)
+
+		var ignoreID []int
+		if idField != "" {
+			id, ok := siblingField(f, idField)
+			if !ok {
+				f.Validation.AddError(f.Key, fmt.Sprintf("unknown id field %s for validation", idField))
+				return false
+			}
+			if n, ok := measure(id); ok && n != 0 {
+				ignoreID = append(ignoreID, int(n))
+			}
+		}
+
+		count, err := f.Validation.countRows(table, column, stringValue(f.Value), ignoreID...)
+		if err != nil {
+			// report why the check could not be made, rather than the rule's message
+			f.Validation.AddError(f.Key, err.Error())
+			return false
+		}
+		return count == 0
+	})
+	RegisterRule("exists", func(f Field, param string) bool {
+		table, column, _ := splitTableColumn(param, f.Key)
+		count, err := f.Validation.countRows(table, column, stringValue(f.Value))
+		if err != nil {
+			// report why the check could not be made, rather than the rule's message
+			f.Validation.AddError(f.Key, err.Error())
+			return false
+		}
+		return count > 0
+	})
+}
+
+// MinLength checks that value is at least n characters long
+func (v *Validation) MinLength(field, value string, n int) {
+	if len([]rune(value)) < n {
+		v.AddError(field, v.message(field, "minlen", "", map[string]string{"param": strconv.Itoa(n)}))
+	}
+}
+
+// MaxLength checks that value is at most n characters long
+func (v *Validation) MaxLength(field, value string, n int) {
+	if len([]rune(value)) > n {
+		v.AddError(field, v.message(field, "maxlen", "", map[string]string{"param": strconv.Itoa(n)}))
+	}
+}
+
+// Between checks that value is a number from min to max, inclusive
+func (v *Validation) Between(field, value string, min, max float64) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < min || n > max {
+		v.AddError(field, v.message(field, "between", "", map[string]string{"min": fmt.Sprint(min), "max": fmt.Sprint(max)}))
+	}
+}
+
+// Matches checks value against a regular expression
+func (v *Validation) Matches(field, value string, re *regexp.Regexp) {
+	if !re.MatchString(value) {
+		v.AddError(field, v.message(field, "regex", "", nil))
+	}
+}
+
+// In checks that value is one of allowed
+func (v *Validation) In(field, value string, allowed ...string) {
+	if !inSlice(allowed, value) {
+		v.AddError(field, v.message(field, "in", "", map[string]string{"param": strings.Join(allowed, ", ")}))
+	}
+}
+
+// NotIn checks that value is none of forbidden
+func (v *Validation) NotIn(field, value string, forbidden ...string) {
+	if inSlice(forbidden, value) {
+		v.AddError(field, v.message(field, "notin", "", nil))
+	}
+}
+
+// IsURL checks that value is an absolute http or https URL
+func (v *Validation) IsURL(field, value string) {
+	if !isURL(value) {
+		v.AddError(field, v.message(field, "url", "", nil))
+	}
+}
+
+// IsUUID checks that value is a UUID
+func (v *Validation) IsUUID(field, value string) {
+	if !govalidator.IsUUID(value) {
+		v.AddError(field, v.message(field, "uuid", "", nil))
+	}
+}
+
+// DateBetween checks that value is a date in the form of YYYY-MM-DD from after to before, inclusive.
+// A zero after or before leaves that side open.
+func (v *Validation) DateBetween(field, value string, after, before time.Time) {
+	d, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		v.AddError(field, v.message(field, "dateiso", "", nil))
+		return
+	}
+
+	if !after.IsZero() && d.Before(after) {
+		v.AddError(field, v.message(field, "afterequal", "", map[string]string{"param": after.Format("2006-01-02")}))
+	}
+
+	if !before.IsZero() && d.After(before) {
+		v.AddError(field, v.message(field, "beforeequal", "", map[string]string{"param": before.Format("2006-01-02")}))
+	}
+}
+
+// PasswordStrength checks that value is at least minLength characters long, and contains upper and
+// lower case letters, a digit and a symbol
+func (v *Validation) PasswordStrength(field, value string, minLength int) {
+	if !isStrongPassword(value, minLength) {
+		v.AddError(field, v.message(field, "password", "", map[string]string{"param": strconv.Itoa(minLength)}))
+	}
+}
+
+// Confirmed checks that a value and its confirmation, e.g. a password typed twice, match
+func (v *Validation) Confirmed(field, value, confirmation string) {
+	if value != confirmation {
+		v.AddError(field, v.message(field, "confirmed", "", nil))
+	}
+}
+
+// Unique checks that no row in table has value in column, e.g. that an email address is not yet
+// registered. Pass the id of the row being edited as ignoreID to skip it.
+func (v *Validation) Unique(field, value, table, column string, ignoreID ...int) {
+	count, err := v.countRows(table, column, value, ignoreID...)
+	if err != nil {
+		v.AddError(field, err.Error())
+		return
+	}
+
+	if count > 0 {
+		v.AddError(field, v.message(field, "unique", "", nil))
+	}
+}
+
+// Exists checks that a row in table has value in column, e.g. that a selected category exists
+func (v *Validation) Exists(field, value, table, column string) {
+	count, err := v.countRows(table, column, value)
+	if err != nil {
+		v.AddError(field, err.Error())
+		return
+	}
+
+	if count == 0 {
+		v.AddError(field, v.message(field, "exists", "", nil))
+	}
+}
+
+// countRows counts the rows in table where column equals value, using the application's database
+func (v *Validation) countRows(table, column, value string, ignoreID ...int) (int, error) {
+	if v.db == nil {
+		return 0, fmt.Errorf("no database connection for validation")
+	}
+
+	if !identifierRegexp.MatchString(table) || !identifierRegexp.MatchString(column) {
+		return 0, fmt.Errorf("invalid table or column name for validation")
+	}
+
+	query := fmt.Sprintf("select count(*) from %s where %s = %s", table, column, v.placeholder(1))
+	args := []interface{}{value}
+
+	if len(ignoreID) > 0 {
+		query = fmt.Sprintf("%s and id <> %s", query, v.placeholder(2))
+		args = append(args, ignoreID[0])
+	}
+
+	var count int
+	err := v.db.QueryRow(query, args...).Scan(&count)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// placeholder returns the n-th query placeholder for the database type
+func (v *Validation) placeholder(n int) string {
+	return placeholder(v.dbType, n)
+}
+
+// placeholder returns the n-th query placeholder for a database type: $n for postgres, ? otherwise
+func placeholder(dbType string, n int) string {
+	switch strings.ToLower(dbType) {
+	case "postgres", "postgresql", "pgx":
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// ruleConfirmed compares the field with its confirmation field: the one named in the parameter, or
+// the one whose key is the field's key followed by _confirmation
+func ruleConfirmed(f Field, param string) bool {
+	want := param
+	if want == "" {
+		want = f.Key[strings.LastIndex(f.Key, ".")+1:] + "_confirmation"
+	}
+
+	confirmation, ok := siblingField(f, want)
+	return ok && stringValue(confirmation) == stringValue(f.Value)
+}
+
+// siblingField finds the field of the struct f belongs to that has name as its name or key
+func siblingField(f Field, name string) (reflect.Value, bool) {
+	rt := f.Parent.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		if sf.Name == name || f.Validation.fieldKey(sf) == name {
+			return f.Parent.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+func isURL(s string) bool {
+	return govalidator.IsRequestURL(s) && (strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://"))
+}
+
+func isStrongPassword(s string, minLength int) bool {
+	var upper, lower, digit, symbol bool
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	return len([]rune(s)) >= minLength && upper && lower && digit && symbol
+}
+
+func parseRange(param string) (float64, float64, error) {
+	parts := strings.SplitN(param, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %s", param)
+	}
+
+	min, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	max, err := strconv.ParseFloat(parts[1], 64)
+	return min, max, err
+}
+
+// parseDates parses a field holding a time.Time or a YYYY-MM-DD string, and a limit that is either
+// a YYYY-MM-DD date or "today"
+func parseDates(value reflect.Value, param string) (time.Time, time.Time, bool) {
+	var d time.Time
+	if t, ok := reflect.Indirect(value).Interface().(time.Time); ok {
+		d = t
+	} else {
+		parsed, err := time.Parse("2006-01-02", stringValue(value))
+		if err != nil {
+			return d, d, false
+		}
+		d = parsed
+	}
+
+	if param == "today" {
+		now := time.Now().UTC()
+		return d, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), true
+	}
+
+	limit, err := time.Parse("2006-01-02", param)
+	if err != nil {
+		return d, d, false
+	}
+
+	return d, limit, true
+}
+
+// splitTableColumn splits a table:column:idField parameter; the column defaults to the field's
+// key, and the id field is optional
+func splitTableColumn(param, key string) (string, string, string) {
+	parts := append(strings.SplitN(param, ":", 3), "", "")
+	if parts[1] == "" {
+		parts[1] = key[strings.LastIndex(key, ".")+1:]
+	}
+	return parts[0], parts[1], parts[2]
+}
@@ -0,0 +1,190 @@
+package bendis
+
+import (
+	"database/sql"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestValidation_LibraryRules(t *testing.T) {
+	type form struct {
+		Password             string `form:"password"`
+		PasswordConfirmation string `form:"password_confirmation"`
+		Repeat               string
+	}
+
+	parent := reflect.ValueOf(form{Password: "secret", PasswordConfirmation: "secret", Repeat: "other"})
+	tomorrow := time.Now().AddDate(0, 0, 1)
+
+	var tests = []struct {
+		name  string
+		key   string
+		tag   string
+		value interface{}
+		valid bool
+	}{
+		{"minlen", "value", "minlen=3", "abc", true},
+		{"minlen short", "value", "minlen=3", "ab", false},
+		{"maxlen", "value", "maxlen=3", "äöü", true},
+		{"maxlen long", "value", "maxlen=3", "abcd", false},
+		{"between", "value", "between=1:10", "10", true},
+		{"between outside", "value", "between=1:10", "11", false},
+		{"between not a number", "value", "between=1:10", "x", false},
+		{"regex", "value", "regex=^[a-z]+$", "abc", true},
+		{"regex no match", "value", "regex=^[a-z]+$", "ABC", false},
+		{"in", "value", "in=red|green", "green", true},
+		{"in missing", "value", "in=red|green", "blue", false},
+		{"notin", "value", "notin=admin|root", "jack", true},
+		{"notin forbidden", "value", "notin=admin|root", "root", false},
+		{"url", "value", "url", "https://example.com/x", true},
+		{"url relative", "value", "url", "/x", false},
+		{"url other scheme", "value", "url", "ftp://example.com", false},
+		{"uuid", "value", "uuid", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", true},
+		{"uuid invalid", "value", "uuid", "6ba7b810", false},
+		{"after", "value", "after=2020-01-01", "2020-01-02", true},
+		{"after same day", "value", "after=2020-01-01", "2020-01-01", false},
+		{"after today", "value", "after=today", tomorrow, true},
+		{"before", "value", "before=2020-01-01", "2019-12-31", true},
+		{"before today", "value", "before=today", tomorrow, false},
+		{"password", "value", "password", "Secret-123", true},
+		{"password short", "value", "password", "Se-1", false},
+		{"password no symbol", "value", "password=4", "Secret123", false},
+		{"confirmed", "password", "confirmed", "secret", true},
+		{"confirmed mismatch", "password", "confirmed", "other", false},
+		{"confirmed named", "value", "confirmed=Repeat", "other", true},
+		{"confirmed missing field", "value", "confirmed", "x", false},
+	}
+
+	for _, e := range tests {
+		v := &Validation{Errors: make(map[string]string)}
+		v.validateField(Field{Key: e.key, Value: reflect.ValueOf(e.value), Parent: parent, Validation: v}, e.tag)
+
+		if v.Valid() != e.valid {
+			t.Errorf("%s: expected valid to be %t, got errors %v", e.name, e.valid, v.Errors)
+		}
+	}
+}
+
+func TestValidation_Methods(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	var tests = []struct {
+		name  string
+		check func(v *Validation)
+		valid bool
+	}{
+		{"MinLength", func(v *Validation) { v.MinLength("f", "abc", 3) }, true},
+		{"MinLength short", func(v *Validation) { v.MinLength("f", "ab", 3) }, false},
+		{"MaxLength long", func(v *Validation) { v.MaxLength("f", "abcd", 3) }, false},
+		{"Between", func(v *Validation) { v.Between("f", "5", 1, 10) }, true},
+		{"Between outside", func(v *Validation) { v.Between("f", "0", 1, 10) }, false},
+		{"Matches", func(v *Validation) { v.Matches("f", "a,b", regexp.MustCompile(`^\w,\w$`)) }, true},
+		{"In", func(v *Validation) { v.In("f", "b", "a", "b") }, true},
+		{"NotIn", func(v *Validation) { v.NotIn("f", "b", "a", "b") }, false},
+		{"IsURL", func(v *Validation) { v.IsURL("f", "http://example.com") }, true},
+		{"IsUUID", func(v *Validation) { v.IsUUID("f", "nope") }, false},
+		{"DateBetween", func(v *Validation) { v.DateBetween("f", "2020-06-01", day("2020-01-01"), day("2020-12-31")) }, true},
+		{"DateBetween inclusive", func(v *Validation) { v.DateBetween("f", "2020-01-01", day("2020-01-01"), time.Time{}) }, true},
+		{"DateBetween too early", func(v *Validation) { v.DateBetween("f", "2019-06-01", day("2020-01-01"), time.Time{}) }, false},
+		{"DateBetween not a date", func(v *Validation) { v.DateBetween("f", "soon", time.Time{}, time.Time{}) }, false},
+		{"PasswordStrength", func(v *Validation) { v.PasswordStrength("f", "Secret-123", 10) }, true},
+		{"PasswordStrength short", func(v *Validation) { v.PasswordStrength("f", "Secret-123", 12) }, false},
+		{"Confirmed", func(v *Validation) { v.Confirmed("f", "a", "b") }, false},
+	}
+
+	for _, e := range tests {
+		v := &Validation{Errors: make(map[string]string)}
+		e.check(v)
+
+		if v.Valid() != e.valid {
+			t.Errorf("%s: expected valid to be %t, got errors %v", e.name, e.valid, v.Errors)
+		}
+	}
+}
+
+func TestValidation_DatabaseRules(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// a memory database lives as long as its connection
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`create table users (id integer primary key, email text);
+		insert into users (id, email) values (1, 'taken@here.com'), (2, 'other@here.com')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type user struct {
+		ID    int    `form:"id"`
+		Email string `form:"email"`
+	}
+
+	var tests = []struct {
+		name  string
+		tag   string
+		user  user
+		valid bool
+	}{
+		{"unique", "unique=users", user{Email: "new@here.com"}, true},
+		{"unique taken", "unique=users", user{Email: "taken@here.com"}, false},
+		{"unique column", "unique=users:email", user{Email: "taken@here.com"}, false},
+		{"unique ignores the row being edited", "unique=users:email:ID", user{ID: 1, Email: "taken@here.com"}, true},
+		{"unique id field by key", "unique=users::id", user{ID: 1, Email: "taken@here.com"}, true},
+		{"unique taken by another row", "unique=users:email:ID", user{ID: 2, Email: "taken@here.com"}, false},
+		{"unique new row", "unique=users:email:ID", user{Email: "taken@here.com"}, false},
+		{"unique unknown id field", "unique=users:email:UserID", user{ID: 1, Email: "taken@here.com"}, false},
+		{"exists", "exists=users:email", user{Email: "taken@here.com"}, true},
+		{"exists missing", "exists=users:email", user{Email: "new@here.com"}, false},
+		{"invalid table", "exists=users;drop", user{Email: "taken@here.com"}, false},
+	}
+
+	for _, e := range tests {
+		v := &Validation{Errors: make(map[string]string), db: db, dbType: "sqlite"}
+		parent := reflect.ValueOf(e.user)
+		v.validateField(Field{Key: "email", Value: parent.Field(1), Parent: parent, Validation: v}, e.tag)
+
+		if v.Valid() != e.valid {
+			t.Errorf("%s: expected valid to be %t, got errors %v", e.name, e.valid, v.Errors)
+		}
+	}
+
+	v := &Validation{Errors: make(map[string]string), db: db, dbType: "sqlite"}
+	v.Unique("email", "taken@here.com", "users", "email", 1)
+	v.Exists("email", "other@here.com", "users", "email")
+	if !v.Valid() {
+		t.Errorf("expected no errors, got %v", v.Errors)
+	}
+
+	v = &Validation{Errors: make(map[string]string)}
+	v.Unique("email", "taken@here.com", "users", "email")
+	if v.Errors["email"] != "no database connection for validation" {
+		t.Errorf("expected an error without a database, got %v", v.Errors)
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	var tests = []struct {
+		dbType   string
+		expected string
+	}{
+		{"postgres", "$2"},
+		{"pgx", "$2"},
+		{"mysql", "?"},
+		{"sqlite", "?"},
+	}
+
+	for _, e := range tests {
+		if p := placeholder(e.dbType, 2); p != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.dbType, e.expected, p)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package bendis
+
+import (
+	"errors"
+	"fmt"
+	"github.com/asaskevich/govalidator"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field is a struct field being validated against a rule from its validate tag
+type Field struct {
+	Key        string        // the error key: the form or json name of the field
+	Value      reflect.Value // the value of the field
+	Parent     reflect.Value // the struct the field belongs to
+	Validation *Validation
+}
+
+// FieldRule checks a field against a validate tag rule, e.g. min=3 calls the min rule with param "3"
+type FieldRule func(f Field, param string) bool
+
+var fieldRules = map[string]FieldRule{}
+
+// ruleDefaults holds the parameter used when a rule is given without one
+var ruleDefaults = map[string]string{}
+
+// RegisterRule adds a rule for validate tags. A default parameter, if given, is used when the rule
+// appears in a tag without one. The message shown when it fails is looked up under the rule's
+// name, so add one for each locale with RegisterMessages.
+func RegisterRule(name string, rule FieldRule, defaultParam ...string) {
+	fieldRules[name] = rule
+
+	delete(ruleDefaults, name)
+	if len(defaultParam) > 0 {
+		ruleDefaults[name] = defaultParam[0]
+	}
+}
+
+// strings are measured in characters, and slices in items, so min and max have messages for each
+func init() {
+	RegisterRule("required", func(f Field, param string) bool { return !isEmptyValue(f.Value) })
+	RegisterRule("min", ruleMin)
+	RegisterRule("max", ruleMax)
+	RegisterRule("email", stringRule(govalidator.IsEmail))
+	RegisterRule("int", stringRule(func(s string) bool { _, err := strconv.Atoi(s); return err == nil }))
+	RegisterRule("float", stringRule(func(s string) bool { _, err := strconv.ParseFloat(s, 64); return err == nil }))
+	RegisterRule("dateiso", stringRule(func(s string) bool { _, err := time.Parse("2006-01-02", s); return err == nil }))
+	RegisterRule("nospaces", stringRule(func(s string) bool { return !govalidator.HasWhitespace(s) }))
+}
+
+// ValidateStruct checks every field of the struct data points to against the rules in its validate
+// tag, e.g. `validate:"required,min=3,max=64"`, and adds an error for the first rule each field
+// fails. Errors are keyed by the form tag of the field, or the json tag, or the field name, so the
+// keys match the names used in the form or JSON body. Rules other than required are skipped for
+// empty values. A label tag, e.g. `label:"Email address"`, names the field in messages.
+func (v *Validation) ValidateStruct(data interface{}) {
+	seen := make(map[uintptr]bool)
+	if ptr := reflect.ValueOf(data); ptr.Kind() == reflect.Ptr && !ptr.IsNil() {
+		seen[ptr.Pointer()] = true
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(data))
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	v.validateStruct(rv, "", seen)
+}
+
+// validateStruct validates the fields of rv, and of the structs nested in it. seen holds the
+// structs that were reached through a pointer, so that a cycle of pointers ends.
+func (v *Validation) validateStruct(rv reflect.Value, prefix string, seen map[uintptr]bool) {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		key := prefix + v.fieldKey(sf)
+		value := rv.Field(i)
+
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+
+		v.tagLabel(key, sf)
+
+		if tag != "" {
+			v.validateField(Field{Key: key, Value: value, Parent: rv, Validation: v}, tag)
+		}
+
+		// validate nested structs, e.g. an address inside a customer
+		if value.Kind() == reflect.Ptr && !value.IsNil() {
+			if seen[value.Pointer()] {
+				continue
+			}
+			seen[value.Pointer()] = true
+		}
+
+		nested := reflect.Indirect(value)
+		if nested.Kind() == reflect.Struct && nested.Type() != reflect.TypeOf(time.Time{}) {
+			v.validateStruct(nested, key+".", seen)
+		}
+	}
+}
+
+func (v *Validation) validateField(f Field, tag string) {
+	empty := isEmptyValue(f.Value)
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if param == "" {
+			param = ruleDefaults[name]
+		}
+
+		if empty && name != "required" {
+			continue
+		}
+
+		check, ok := fieldRules[name]
+		if !ok {
+			v.AddError(f.Key, fmt.Sprintf("unknown validation rule %s", name))
+			return
+		}
+
+		if !check(f, param) {
+			v.AddError(f.Key, v.message(f.Key, name, kindName(f.Value), ruleParams(param)))
+			return
+		}
+	}
+}
+
+// BindForm parses the request's form, copies the values into the struct dst points to, and
+// validates it. Values that cannot be converted to the type of their field are reported as errors.
+func (b *Bendis) BindForm(r *http.Request, dst interface{}) (*Validation, error) {
+	err := r.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("BindForm needs a pointer to a struct")
+	}
+
+	v := b.Validator(r.Form).ForRequest(r)
+	v.bindForm(rv.Elem(), "")
+	v.ValidateStruct(dst)
+
+	return v, nil
+}
+
+// BindJSON reads the JSON body into the struct dst points to with ReadJSON, and validates it
+func (b *Bendis) BindJSON(w http.ResponseWriter, r *http.Request, dst interface{}) (*Validation, error) {
+	err := b.ReadJSON(w, r, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	v := b.Validator(nil).ForRequest(r)
+	v.keyTag = "json"
+	v.ValidateStruct(dst)
+
+	return v, nil
+}
+
+func (v *Validation) bindForm(rv reflect.Value, prefix string) {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" || sf.Tag.Get("form") == "-" {
+			continue
+		}
+
+		key := prefix + v.fieldKey(sf)
+		field := rv.Field(i)
+		v.tagLabel(key, sf)
+
+		if field.Kind() == reflect.Struct && field.Type() != reflect.TypeOf(time.Time{}) {
+			v.bindForm(field, key+".")
+			continue
+		}
+
+		values, ok := v.Data[key]
+		if !ok {
+			continue
+		}
+
+		err := setField(field, values)
+		if err != nil {
+			v.AddError(key, v.message(key, "invalid", "", nil))
+		}
+	}
+}
+
+// setField converts form values to the type of field, and sets it
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	value := ""
+	if len(values) > 0 {
+		value = values[0]
+	}
+	return setValue(field, value)
+}
+
+func setValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		if value == "" {
+			return nil
+		}
+		ptr := reflect.New(field.Type().Elem())
+		if err := setValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		if value == "" {
+			return nil
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Bool:
+		// unchecked checkboxes are not submitted at all, so anything sent counts, except an explicit false
+		switch strings.ToLower(value) {
+		case "", "0", "false", "off", "no":
+			field.SetBool(false)
+		default:
+			field.SetBool(true)
+		}
+	default:
+		return fmt.Errorf("cannot bind form values to %s", field.Type())
+	}
+
+	return nil
+}
+
+// fieldKey returns the name a struct field has in forms and JSON: its form tag, json tag, or name.
+// When validating JSON, the json tag is preferred.
+func (v *Validation) fieldKey(sf reflect.StructField) string {
+	tags := []string{"form", "json"}
+	if v.keyTag == "json" {
+		tags = []string{"json", "form"}
+	}
+
+	for _, tag := range tags {
+		name := strings.Split(sf.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// tagLabel uses the label tag of a struct field, unless a label for key has been set already
+func (v *Validation) tagLabel(key string, sf reflect.StructField) {
+	label := sf.Tag.Get("label")
+	if label == "" {
+		return
+	}
+
+	if _, ok := v.Labels[key]; !ok {
+		v.SetLabel(key, label)
+	}
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return t.IsZero()
+		}
+		return false
+	}
+	return v.IsZero()
+}
+
+// kindName groups value kinds for messages: string, number, slice or other
+func kindName(v reflect.Value) string {
+	switch reflect.Indirect(v).Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return "slice"
+	}
+	return "other"
+}
+
+// stringRule turns a check on a string into a FieldRule. Non-string values are formatted first.
+func stringRule(check func(string) bool) FieldRule {
+	return func(f Field, param string) bool {
+		return check(stringValue(f.Value))
+	}
+}
+
+func stringValue(v reflect.Value) string {
+	v = reflect.Indirect(v)
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// ruleMin checks the length of strings and slices, and the value of numbers
+func ruleMin(f Field, param string) bool {
+	size, ok := measure(f.Value)
+	limit, err := strconv.ParseFloat(param, 64)
+	return ok && err == nil && size >= limit
+}
+
+// ruleMax checks the length of strings and slices, and the value of numbers
+func ruleMax(f Field, param string) bool {
+	size, ok := measure(f.Value)
+	limit, err := strconv.ParseFloat(param, 64)
+	return ok && err == nil && size <= limit
+}
+
+// measure returns the number of characters of a string, the length of a slice, or a number's value
+func measure(v reflect.Value) (float64, bool) {
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,234 @@
+package bendis
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidation_TagRules(t *testing.T) {
+	type form struct {
+		Value interface{}
+	}
+
+	var tests = []struct {
+		name  string
+		tag   string
+		value interface{}
+		valid bool
+	}{
+		{"required", "required", "x", true},
+		{"required blank", "required", "  ", false},
+		{"required zero", "required", 0, false},
+		{"required nil slice", "required", []string(nil), false},
+		{"empty skips rules", "min=3", "", true},
+		{"min string", "min=3", "abc", true},
+		{"min string short", "min=3", "ab", false},
+		{"min counts characters", "min=3", "äöü", true},
+		{"min number", "min=18", 17, false},
+		{"max number", "max=10", 10, true},
+		{"max slice", "max=2", []string{"a", "b", "c"}, false},
+		{"email", "email", "me@here.com", true},
+		{"email invalid", "email", "me@", false},
+		{"int", "int", "12", true},
+		{"int invalid", "int", "1.5", false},
+		{"float", "float", "1.5", true},
+		{"float invalid", "float", "x", false},
+		{"dateiso", "dateiso", "2021-04-01", true},
+		{"dateiso invalid", "dateiso", "01/04/2021", false},
+		{"nospaces", "nospaces", "abc", true},
+		{"nospaces invalid", "nospaces", "a bc", false},
+		{"several rules", "required,min=2,max=4", "abcde", false},
+		{"unknown rule", "nosuchrule", "x", false},
+	}
+
+	for _, e := range tests {
+		v := &Validation{Errors: make(map[string]string)}
+		v.validateField(Field{Key: "value", Value: reflect.ValueOf(e.value), Parent: reflect.ValueOf(form{}), Validation: v}, e.tag)
+
+		if v.Valid() != e.valid {
+			t.Errorf("%s: expected valid to be %t, got errors %v", e.name, e.valid, v.Errors)
+		}
+	}
+}
+
+func TestValidation_ValidateStruct(t *testing.T) {
+	type address struct {
+		City string `form:"city" validate:"required"`
+	}
+
+	type customer struct {
+		Name     string   `form:"name" label:"Full name" validate:"required"`
+		Email    string   `json:"email" validate:"required,email"`
+		Address  address  `form:"address"`
+		Billing  *address `form:"billing"`
+		Shipping *address `form:"shipping"`
+		Skipped  string   `validate:"-"`
+		internal string
+	}
+
+	c := customer{Email: "me@", Billing: &address{}}
+	v := &Validation{Errors: make(map[string]string), Labels: make(map[string]string)}
+	v.ValidateStruct(&c)
+
+	for _, key := range []string{"name", "email", "address.city", "billing.city"} {
+		if _, ok := v.Errors[key]; !ok {
+			t.Errorf("expected an error for %s, got %v", key, v.Errors)
+		}
+	}
+
+	if len(v.Errors) != 4 {
+		t.Errorf("expected 4 errors, got %v", v.Errors)
+	}
+
+	if v.Errors["name"] != "Full name cannot be blank" {
+		t.Errorf("expected the label in the message, got %s", v.Errors["name"])
+	}
+}
+
+func TestValidation_ValidateStruct_Cycle(t *testing.T) {
+	type node struct {
+		Name string `validate:"required"`
+		Next *node
+	}
+
+	first := &node{Name: "first"}
+	second := &node{Next: first}
+	first.Next = second
+
+	done := make(chan bool)
+	go func() {
+		v := &Validation{Errors: make(map[string]string)}
+		v.ValidateStruct(first)
+		done <- v.Errors["Next.Name"] != ""
+	}()
+
+	select {
+	case found := <-done:
+		if !found {
+			t.Error("expected an error for the second node")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ValidateStruct did not return for a cycle of pointers")
+	}
+}
+
+func TestBendis_BindForm(t *testing.T) {
+	type address struct {
+		City string `form:"city" validate:"required"`
+	}
+
+	type signup struct {
+		Name     string    `form:"name" validate:"required"`
+		Age      int       `form:"age" validate:"min=18"`
+		Score    float64   `form:"score"`
+		Count    uint8     `form:"count"`
+		Nickname *string   `form:"nickname"`
+		Born     time.Time `form:"born"`
+		Terms    bool      `form:"terms"`
+		News     bool      `form:"news"`
+		Colors   []string  `form:"colors"`
+		Ids      []int     `form:"ids"`
+		Address  address   `form:"address"`
+		Ignored  string    `form:"-"`
+	}
+
+	b := &Bendis{}
+
+	var tests = []struct {
+		name   string
+		form   url.Values
+		errors []string
+		check  func(s signup) bool
+	}{
+		{
+			"every type",
+			url.Values{
+				"name": {"Jack"}, "age": {"21"}, "score": {"1.5"}, "count": {"7"}, "nickname": {"jj"},
+				"born": {"2000-01-02"}, "terms": {"on"}, "news": {"false"}, "colors": {"red", "blue"},
+				"ids": {"1", "2"}, "address.city": {"Berlin"}, "Ignored": {"x"},
+			},
+			nil,
+			func(s signup) bool {
+				return s.Name == "Jack" && s.Age == 21 && s.Score == 1.5 && s.Count == 7 && *s.Nickname == "jj" &&
+					s.Born.Format("2006-01-02") == "2000-01-02" && s.Terms && !s.News &&
+					reflect.DeepEqual(s.Colors, []string{"red", "blue"}) && reflect.DeepEqual(s.Ids, []int{1, 2}) &&
+					s.Address.City == "Berlin" && s.Ignored == ""
+			},
+		},
+		{
+			"blank values are left alone",
+			url.Values{"name": {"Jack"}, "age": {""}, "nickname": {""}, "born": {""}, "address.city": {"Berlin"}},
+			nil,
+			func(s signup) bool { return s.Age == 0 && s.Nickname == nil && s.Born.IsZero() },
+		},
+		{
+			"rules fail",
+			url.Values{"age": {"17"}},
+			[]string{"name", "age", "address.city"},
+			nil,
+		},
+		{
+			"values of the wrong type",
+			url.Values{"name": {"Jack"}, "age": {"old"}, "count": {"300"}, "ids": {"1", "x"}, "born": {"yesterday"}, "address.city": {"Berlin"}},
+			[]string{"age", "count", "ids", "born"},
+			nil,
+		},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(e.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		var s signup
+		v, err := b.BindForm(r, &s)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+
+		if len(v.Errors) != len(e.errors) {
+			t.Errorf("%s: expected errors for %v, got %v", e.name, e.errors, v.Errors)
+		}
+		for _, key := range e.errors {
+			if _, ok := v.Errors[key]; !ok {
+				t.Errorf("%s: expected an error for %s, got %v", e.name, key, v.Errors)
+			}
+		}
+
+		if e.check != nil && !e.check(s) {
+			t.Errorf("%s: unexpected values %+v", e.name, s)
+		}
+	}
+
+	_, err := b.BindForm(httptest.NewRequest("POST", "/", nil), signup{})
+	if err == nil {
+		t.Error("expected an error binding to a struct that is not a pointer")
+	}
+}
+
+func TestBendis_BindJSON(t *testing.T) {
+	type login struct {
+		Email    string `json:"email_address" form:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	b := &Bendis{}
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"email_address": "me@"}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	var l login
+	v, err := b.BindJSON(httptest.NewRecorder(), r, &l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"email_address", "password"} {
+		if _, ok := v.Errors[key]; !ok {
+			t.Errorf("expected an error keyed by the json name %s, got %v", key, v.Errors)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package bendis
 
 import (
+	"database/sql"
 	"github.com/asaskevich/govalidator"
 	"net/http"
 	"net/url"
@@ -10,14 +11,25 @@ import (
 )
 
 type Validation struct {
-	Data  url.Values
-	Errors map[string]string
+	Data     url.Values
+	Errors   map[string]string
+	Locale   string            // the locale messages are shown in, e.g. en or de
+	Labels   map[string]string // names fields are called by in messages, keyed by field
+	Messages Messages          // message overrides, keyed by rule or field.rule
+	keyTag   string
+	db       *sql.DB
+	dbType   string
 }
 
 func (b *Bendis) Validator(data url.Values) *Validation {
 	return &Validation{
-		Data:   data,
-		Errors: make(map[string]string),
+		Data:     data,
+		Errors:   make(map[string]string),
+		Locale:   b.config.locale,
+		Labels:   make(map[string]string),
+		Messages: make(Messages),
+		db:       b.DB.Pool,
+		dbType:   b.DB.DatabaseType,
 	}
 }
 
@@ -43,7 +55,7 @@ func (v *Validation) Required(r *http.Request, fields ...string) {
 	for _, field := range fields {
 		value := r.Form.Get(field)
 		if strings.TrimSpace(value) == "" {
-			v.AddError(field, "This field cannot be blank")
+			v.AddError(field, v.message(field, "required", "", nil))
 		}
 	}
 }
@@ -56,33 +68,33 @@ func (v *Validation) Check(ok bool, key, message string) {
 
 func (v *Validation) IsEmail(field, value string) {
 	if !govalidator.IsEmail(value) {
-		v.AddError(field, "invalid email address")
+		v.AddError(field, v.message(field, "email", "", nil))
 	}
 }
 
 func (v *Validation) IsInt(field, value string) {
 	_, err := strconv.Atoi(value)
 	if err != nil {
-		v.AddError(field, "This field must be an integer")
+		v.AddError(field, v.message(field, "int", "", nil))
 	}
 }
 
 func (v *Validation) IsFloat(field, value string)  {
 	_, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		v.AddError(field, "This field must be a floating point number")
+		v.AddError(field, v.message(field, "float", "", nil))
 	}
 }
 
 func (v *Validation) IsDateISO(field, value string)  {
 	_, err := time.Parse("2006-01-02", value)
 	if err != nil {
-		v.AddError(field, "This field must be a date in the form of YYYY-MM-DD")
+		v.AddError(field, v.message(field, "dateiso", "", nil))
 	}
 }
 
 func (v *Validation) NoSpaces(field, value string) {
 	if govalidator.HasWhitespace(value) {
-		v.AddError(field, "Spaces are not permitted")
+		v.AddError(field, v.message(field, "nospaces", "", nil))
 	}
 }
\ No newline at end of file